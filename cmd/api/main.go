@@ -2,8 +2,10 @@ package main
 
 import (
 	"log"
+	"strings"
 	"time"
 
+	config "github.com/anjiri1684/language_tutor/configs"
 	"github.com/anjiri1684/language_tutor/database"
 	"github.com/anjiri1684/language_tutor/jobs"
 	"github.com/anjiri1684/language_tutor/notifications"
@@ -29,15 +31,36 @@ func main() {
 	c := cron.New()
 	c.AddFunc("*/5 * * * *", jobs.CheckForUnattendedClasses)
 	c.AddFunc("*/5 * * * *", jobs.SendClassReminders) 
+	c.AddFunc("0 * * * *", jobs.GenerateRecurringAvailability)
+	c.AddFunc("*/5 * * * *", jobs.ExpireUnpaidBookings)
+	c.AddFunc("* * * * *", jobs.ProcessWaitlistOffers)
+	c.AddFunc("30 * * * *", jobs.PurgeExpiredIdempotencyKeys)
 	go c.Start()
 	log.Println("✅ Cron job for attendance scheduled successfully.")
 
+	// Only trust the forwarded header from our own load balancers, otherwise anyone could set it
+	// and walk past the webhook IP allowlists.
+	proxyHeader := config.Config("PROXY_HEADER")
+	var trustedProxies []string
+	for _, proxy := range strings.Split(config.Config("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if proxyHeader != "" && len(trustedProxies) == 0 {
+		log.Fatal("PROXY_HEADER is set but TRUSTED_PROXIES is empty; list the load balancer addresses allowed to set it")
+	}
+
 	app := fiber.New(fiber.Config{
 		Prefork:             false,
 		AppName:             "Language Tutor",
 		CaseSensitive:       true,
 		StrictRouting:       true,
 		EnablePrintRoutes:   true,
+		// Behind a load balancer c.IP() must come from the forwarded header for webhook IP allowlists.
+		ProxyHeader:         proxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:      trustedProxies,
 		PassLocalsToViews:   true,
 		ReadTimeout:         15 * time.Second,
 		WriteTimeout:        15 * time.Second,
@@ -61,16 +84,20 @@ func main() {
 
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "*", 
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, Sec-WebSocket-Key, Sec-WebSocket-Version",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, Sec-WebSocket-Key, Sec-WebSocket-Version, Idempotency-Key",
 		AllowMethods:     "GET, POST, PUT, PATCH, DELETE, OPTIONS",
-		ExposeHeaders:    "Content-Length, Authorization",
+		ExposeHeaders:    "Content-Length, Authorization, Idempotent-Replayed",
 		MaxAge:           86400, 
 	}))
 
 	app.Use(recover.New()) 
+	logTimeZone := config.Config("LOG_TIMEZONE")
+	if _, err := services.ParseTimeZone(logTimeZone); err != nil {
+		logTimeZone = "Africa/Nairobi"
+	}
 	app.Use(logger.New(logger.Config{
 		TimeFormat: "2006-01-02 15:04:05",
-		TimeZone:   "Africa/Nairobi",
+		TimeZone:   logTimeZone,
 		Format:     "[${time}] ${status} - ${latency} ${method} ${path}\n",
 	}))
 
@@ -38,10 +38,17 @@ func Migrate() {
 		&models.User{}, 
 		&models.Teacher{}, 
 		&models.AvailabilitySlot{},
+		&models.AvailabilityRule{},
 		&models.Language{},
 		&models.TeacherLanguage{},
 		&models.Booking{}, 
+		&models.BookingStatusHistory{},
+		&models.WaitlistEntry{},
+		&models.AttendanceEvent{},
 		&models.Payment{},
+		&models.WebhookAuditLog{},
+		&models.Refund{},
+		&models.IdempotencyKey{},
 		&models.Question{}, 
 		&models.MockTest{},  
 		&models.TestAttempt{},   
@@ -56,11 +63,78 @@ func Migrate() {
 		&models.Referral{}, 
 		&models.PayoutRequest{},
 		&models.Resource{},
+		&models.LessonReport{},
+		&models.LessonTopic{},
+		&models.LessonVocabularyItem{},
+		&models.LessonSkillRating{},
+		&models.HomeworkAssignment{},
 ) 
 	if err != nil {
 		log.Fatalf("🔥 Failed to migrate database: %v", err)
 	}
 	fmt.Println("✅ Database migration successful")
+
+	ensureSlotOverlapConstraint()
+	migrateBundlesToMinutes()
+	backfillBookingDurations()
+}
+
+// migrateBundlesToMinutes converts bundles sold as a number of classes into minutes, counting
+// each legacy class as one session of the bundle's language. The legacy columns are renamed once
+// converted, so the conversion only ever runs once.
+func migrateBundlesToMinutes() {
+	migrator := DB.Migrator()
+	if migrator.HasColumn("bundles", "number_of_classes") {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(`UPDATE bundles SET total_minutes = bundles.number_of_classes * COALESCE(NULLIF(l.session_minutes, 0), 60)
+				FROM languages l WHERE l.id = bundles.language_id AND bundles.total_minutes = 0`).Error; err != nil { return err }
+			if err := tx.Exec("ALTER TABLE bundles ALTER COLUMN number_of_classes DROP NOT NULL").Error; err != nil { return err }
+			return tx.Exec("ALTER TABLE bundles RENAME COLUMN number_of_classes TO legacy_number_of_classes").Error
+		})
+		if err != nil {
+			log.Printf("⚠️ Could not convert bundles to minutes: %v", err)
+		}
+	}
+	if migrator.HasColumn("student_bundles", "remaining_classes") {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(`UPDATE student_bundles SET remaining_minutes = student_bundles.remaining_classes * COALESCE(NULLIF(l.session_minutes, 0), 60)
+				FROM bundles b JOIN languages l ON l.id = b.language_id
+				WHERE b.id = student_bundles.bundle_id AND student_bundles.remaining_classes > 0`).Error; err != nil { return err }
+			if err := tx.Exec("ALTER TABLE student_bundles ALTER COLUMN remaining_classes DROP NOT NULL").Error; err != nil { return err }
+			return tx.Exec("ALTER TABLE student_bundles RENAME COLUMN remaining_classes TO legacy_remaining_classes").Error
+		})
+		if err != nil {
+			log.Printf("⚠️ Could not convert student bundles to minutes: %v", err)
+		}
+	}
+}
+
+func backfillBookingDurations() {
+	err := DB.Exec(`UPDATE bookings SET duration_minutes = EXTRACT(EPOCH FROM (s.end_time - s.start_time)) / 60
+		FROM availability_slots s WHERE s.id = bookings.availability_slot_id AND bookings.duration_minutes = 0`).Error
+	if err != nil {
+		log.Printf("⚠️ Could not backfill booking durations: %v", err)
+	}
+}
+
+func ensureSlotOverlapConstraint() {
+	var count int64
+	DB.Raw("SELECT COUNT(*) FROM pg_constraint WHERE conname = ?", "availability_slots_no_overlap").Scan(&count)
+	if count > 0 {
+		return
+	}
+
+	if err := DB.Exec("CREATE EXTENSION IF NOT EXISTS btree_gist").Error; err != nil {
+		log.Printf("⚠️ Could not enable btree_gist, slot overlap constraint not created: %v", err)
+		return
+	}
+	err := DB.Exec(`ALTER TABLE availability_slots ADD CONSTRAINT availability_slots_no_overlap
+		EXCLUDE USING gist (teacher_id WITH =, tstzrange(start_time, end_time) WITH &&)`).Error
+	if err != nil {
+		log.Printf("⚠️ Could not create slot overlap constraint (existing overlapping slots?): %v", err)
+		return
+	}
+	fmt.Println("✅ Slot overlap constraint created")
 }
 
 
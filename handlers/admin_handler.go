@@ -10,9 +10,13 @@ import (
 	"strings"
 	"time"
 
+	config "github.com/anjiri1684/language_tutor/configs"
 	"github.com/anjiri1684/language_tutor/database"
 	"github.com/anjiri1684/language_tutor/models"
 	"github.com/anjiri1684/language_tutor/notifications"
+	"github.com/anjiri1684/language_tutor/services"
+	"github.com/anjiri1684/language_tutor/services/bookingstate"
+	"github.com/anjiri1684/language_tutor/websocket"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
@@ -106,7 +110,16 @@ type LanguageRequest struct {
 	Name            string  `json:"name" validate:"required,min=2"`
 	PricePerSession float64 `json:"price_per_session" validate:"required,gt=0"` 
 	Currency        string  `json:"currency" validate:"required,iso4217"`
+	SessionMinutes  int     `json:"session_minutes,omitempty" validate:"omitempty,min=15,max=240"`
+	MinTeacherPrice *float64 `json:"min_teacher_price,omitempty" validate:"omitempty,gt=0"`
+	MaxTeacherPrice *float64 `json:"max_teacher_price,omitempty" validate:"omitempty,gt=0"`
+}
 
+func validateTeacherPriceBounds(req LanguageRequest) error {
+	if req.MinTeacherPrice != nil && req.MaxTeacherPrice != nil && *req.MinTeacherPrice > *req.MaxTeacherPrice {
+		return errors.New("min_teacher_price must not be greater than max_teacher_price")
+	}
+	return nil
 }
 
 
@@ -118,10 +131,17 @@ func CreateLanguage(c *fiber.Ctx) error {
 	if err := validate.Struct(req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
+	if err := validateTeacherPriceBounds(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	language := models.Language{
 		Name: req.Name,
 		PricePerSession: req.PricePerSession,
+		Currency: req.Currency,
+		SessionMinutes: req.SessionMinutes,
+		MinTeacherPrice: req.MinTeacherPrice,
+		MaxTeacherPrice: req.MaxTeacherPrice,
 	}
 	if err := database.DB.Create(&language).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create language"})
@@ -151,9 +171,34 @@ func UpdateLanguage(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	if err := validateTeacherPriceBounds(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	language.Name = req.Name
 	language.PricePerSession = req.PricePerSession 
-	database.DB.Save(&language)
+	if req.SessionMinutes > 0 {
+		language.SessionMinutes = req.SessionMinutes
+	}
+	language.MinTeacherPrice = req.MinTeacherPrice
+	language.MaxTeacherPrice = req.MaxTeacherPrice
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&language).Error; err != nil { return err }
+
+		// Pull existing teacher rates back inside the new bounds.
+		rates := tx.Model(&models.TeacherLanguage{}).Where("language_id = ? AND price_per_session IS NOT NULL", language.ID)
+		if language.MinTeacherPrice != nil {
+			if err := rates.Session(&gorm.Session{}).Where("price_per_session < ?", *language.MinTeacherPrice).Update("price_per_session", *language.MinTeacherPrice).Error; err != nil { return err }
+		}
+		if language.MaxTeacherPrice != nil {
+			if err := rates.Session(&gorm.Session{}).Where("price_per_session > ?", *language.MaxTeacherPrice).Update("price_per_session", *language.MaxTeacherPrice).Error; err != nil { return err }
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update language"})
+	}
 
 	return c.JSON(language)
 }
@@ -198,14 +243,35 @@ func AddMeetingLink(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Meeting links can only be added to confirmed bookings"})
 	}
 
-	booking.MeetingLink = &req.MeetingLink
-	database.DB.Save(&booking)
+	// The room belongs to the slot, so everyone in a group class moves to the new link together.
+	var classmates []models.Booking
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.AvailabilitySlot{}).Where("id = ?", booking.AvailabilitySlotID).Update("meeting_link", req.MeetingLink).Error; err != nil { return err }
+		if err := tx.Preload("Student").
+			Where("availability_slot_id = ? AND status IN ?", booking.AvailabilitySlotID, []string{bookingstate.Confirmed, bookingstate.RescheduleRequested}).
+			Find(&classmates).Error; err != nil { return err }
+		return tx.Model(&models.Booking{}).
+			Where("availability_slot_id = ? AND status IN ?", booking.AvailabilitySlotID, []string{bookingstate.Confirmed, bookingstate.RescheduleRequested}).
+			Update("meeting_link", req.MeetingLink).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save meeting link"})
+	}
+
+	for _, classmate := range classmates {
+		websocket.Publish(websocket.EventMeetingLinkAdded, fiber.Map{
+			"booking_id":   classmate.ID,
+			"meeting_link": req.MeetingLink,
+		}, classmate.StudentID, classmate.TeacherID)
+	}
 
 	go func() {
 		emailSubject := "Your Meeting Link is Here!"
 		emailBody := fmt.Sprintf("<h1>Class Link</h1><p>Hi there,</p><p>Here is the link for your upcoming class: <a href='%s'>Join Class</a>.</p>", req.MeetingLink)
 		
-		notifications.SendEmail(booking.Student.FullName, booking.Student.Email, emailSubject, emailBody)
+		for _, classmate := range classmates {
+			notifications.SendEmail(classmate.Student.FullName, classmate.Student.Email, emailSubject, emailBody)
+		}
 		notifications.SendEmail(booking.Teacher.FullName, booking.Teacher.Email, emailSubject, emailBody)
 	}()
 
@@ -213,12 +279,20 @@ func AddMeetingLink(c *fiber.Ctx) error {
 }
 
 
+type TeacherCancellationStat struct {
+	TeacherID         uuid.UUID `json:"teacher_id"`
+	FullName          string    `json:"full_name"`
+	Email             string    `json:"email"`
+	CancellationCount int       `json:"cancellation_count"`
+}
+
 type DashboardAnalyticsResponse struct {
 	TotalStudents       int64           `json:"total_students"`
 	TotalActiveTeachers int64           `json:"total_active_teachers"`
 	TotalRevenue        float64         `json:"total_revenue"`
 	BookingsLast30Days  int64           `json:"bookings_last_30_days"`
 	RecentBookings      []models.Booking `json:"recent_bookings"`
+	FrequentCancellers  []TeacherCancellationStat `json:"frequent_cancellers"`
 }
 
 func GetDashboardAnalytics(c *fiber.Ctx) error {
@@ -237,70 +311,192 @@ func GetDashboardAnalytics(c *fiber.Ctx) error {
 
 	database.DB.Order("created_at desc").Limit(5).Preload("Student").Preload("Teacher").Find(&response.RecentBookings)
 
+	cancellationThreshold, err := strconv.Atoi(config.Config("TEACHER_CANCELLATION_ALERT_THRESHOLD"))
+	if err != nil || cancellationThreshold <= 0 {
+		cancellationThreshold = 3
+	}
+	database.DB.Model(&models.Teacher{}).
+		Select("teachers.user_id as teacher_id, users.full_name, users.email, teachers.cancellation_count").
+		Joins("JOIN users ON users.id = teachers.user_id").
+		Where("teachers.cancellation_count >= ?", cancellationThreshold).
+		Order("teachers.cancellation_count desc").
+		Limit(10).
+		Scan(&response.FrequentCancellers)
+
 	return c.JSON(response)
 }
 
 func ListRefundRequests(c *fiber.Ctx) error {
 	var payments []models.Payment
-	database.DB.Preload("Booking.Student").Where("refund_status = ?", "requested").Find(&payments)
+	database.DB.Preload("Booking.Student").Preload("StudentBundle.Student").Preload("Refunds").
+		Where("refund_status IN ?", []string{"requested", "failed"}).Find(&payments)
 	return c.JSON(payments)
 }
 
+// paymentStudent is whoever made the payment, whether it was for a booking or a bundle.
+func paymentStudent(payment models.Payment) models.User {
+	if payment.BookingID != nil {
+		return payment.Booking.Student
+	}
+	return payment.StudentBundle.Student
+}
+
+func refundErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Payment record not found"})
+	case errors.Is(err, services.ErrPaymentNotRefundable), errors.Is(err, services.ErrRefundExceedsPayment):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrProviderRefundFailed):
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to issue refund"})
+}
+
+// ProcessRefund approves or rejects a student's refund request. Approving cancels the booking if it
+// is still active and sends the money back through the original provider, or to the student's credit
+// balance when refund_to is "credit". amount defaults to what was requested, or the full payment.
 func ProcessRefund(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	adminID, _ := uuid.Parse(claims["user_id"].(string))
 	paymentID := c.Params("paymentId")
 	
 	type ProcessRequest struct {
-		Decision string `json:"decision" validate:"required,oneof=approve reject"`
+		Decision string  `json:"decision" validate:"required,oneof=approve reject"`
+		Amount   float64 `json:"amount" validate:"omitempty,gt=0"`
+		RefundTo string  `json:"refund_to" validate:"omitempty,oneof=original credit"`
 	}
 	var req ProcessRequest
 	if err := c.BodyParser(&req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"}) }
 	if err := validate.Struct(req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()}) }
 
 	var payment models.Payment
-	if err := database.DB.Preload("Booking.Student").First(&payment, "id = ?", paymentID).Error; err != nil {
+	if err := database.DB.Preload("Booking.Student").Preload("StudentBundle.Student").First(&payment, "id = ?", paymentID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Payment record not found"})
 	}
+	if payment.RefundStatus == nil || (*payment.RefundStatus != "requested" && *payment.RefundStatus != "failed") {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "There is no open refund request for this payment"})
+	}
+	student := paymentStudent(payment)
 
+	var refund *models.Refund
 	if req.Decision == "approve" {
+		reason := "Refund approved"
+		if payment.RefundReason != nil && *payment.RefundReason != "" {
+			reason = *payment.RefundReason
+		}
+
+		// Cancelling a bundle booking returns its minutes straight away, which settles the payment.
+		if payment.Provider == "bundle" && payment.Status != "succeeded" {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "The minutes for this booking have already been returned to the bundle"})
+		}
+
+		// Money goes back first, so a refused provider refund leaves the booking untouched for a retry.
+		if payment.Provider != "bundle" {
+			amount := req.Amount
+			if amount == 0 && payment.RefundAmount != nil && payment.Status == "succeeded" {
+				amount = *payment.RefundAmount
+			}
+			issued, err := services.IssueRefund(payment.ID, amount, req.RefundTo == "credit", reason, &adminID)
+			if err != nil { return refundErrorResponse(c, err) }
+			refund = &issued
+		}
+
 		err := database.DB.Transaction(func(tx *gorm.DB) error {
-			approvedStatus := "approved"
-			refundedStatus := "refunded"
-			payment.RefundStatus = &approvedStatus
-			payment.Status = refundedStatus
-			if err := tx.Save(&payment).Error; err != nil { return err }
-
-			var booking models.Booking
-			if err := tx.First(&booking, "id = ?", payment.BookingID).Error; err != nil { return err }
-			booking.Status = "cancelled"
-			if err := tx.Save(&booking).Error; err != nil { return err }
-			
-			var slot models.AvailabilitySlot
-			if err := tx.First(&slot, "id = ?", booking.AvailabilitySlotID).Error; err != nil { return err }
-			slot.Status = "available"
-			if err := tx.Save(&slot).Error; err != nil { return err }
-			
-			if payment.Provider == "credit" {
-				var student models.User
-				if err := tx.First(&student, "id = ?", booking.StudentID).Error; err != nil { return err }
-				student.CreditBalance += payment.Amount
-				if err := tx.Save(&student).Error; err != nil { return err }
+			if payment.Provider == "bundle" {
+				result := tx.Model(&models.Payment{}).Where("id = ? AND status = ?", payment.ID, "succeeded").
+					Updates(map[string]interface{}{"refund_status": "approved", "status": "refunded"})
+				if result.Error != nil { return result.Error }
+				if result.RowsAffected == 0 { return bookingstate.ErrStatusChanged }
 			}
-			
-			return nil
-		})
-		if err != nil { return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update internal records for refund"}) }
 
-		go notifications.SendEmail(payment.Booking.Student.FullName, payment.Booking.Student.Email, "Your Refund has been Processed", "<h1>Refund Processed</h1><p>Your refund request has been approved and processed by our team.</p>")
+			if payment.BookingID != nil {
+				var booking models.Booking
+				if err := tx.First(&booking, "id = ?", payment.BookingID).Error; err != nil { return err }
+
+				// Bookings cancelled by the student or teacher already released their seat, and classes that
+				// already took place keep their history; only the money is left.
+				if bookingstate.CanTransition(booking.Status, bookingstate.Cancelled) {
+					if err := bookingstate.Transition(tx, &booking, bookingstate.Cancelled, bookingstate.UserActor(adminID, "admin"), "Refund approved"); err != nil { return err }
+					if err := services.ReleaseSeat(tx, booking.AvailabilitySlotID); err != nil { return err }
+				}
+
+				if payment.Provider == "bundle" && booking.StudentBundleID != nil {
+					if err := services.ReturnBundleMinutes(tx, *booking.StudentBundleID, booking.DurationMinutes); err != nil { return err }
+				}
+			}
+			return tx.First(&payment, "id = ?", payment.ID).Error
+		})
+		if err != nil { return bookingTransitionResponse(c, err, "Failed to update internal records for refund") }
+
+		message := "<h1>Refund Processed</h1><p>Your refund request has been approved and processed by our team.</p>"
+		switch {
+		case refund != nil && refund.Destination == "credit":
+			message = fmt.Sprintf("<h1>Refund Processed</h1><p>Your refund request has been approved and %.2f has been added to your credit balance.</p>", *refund.CreditAmount)
+		case refund != nil && refund.Status == "processing":
+			message = fmt.Sprintf("<h1>Refund Approved</h1><p>Your refund of %.2f %s has been approved and is on its way to your original payment method.</p>", refund.Amount, refund.Currency)
+		case refund != nil:
+			message = fmt.Sprintf("<h1>Refund Processed</h1><p>Your refund of %.2f %s has been sent to your original payment method.</p>", refund.Amount, refund.Currency)
+		}
+		go notifications.SendEmail(student.FullName, student.Email, "Your Refund has been Processed", message)
 
 	} else { 
 		rejectedStatus := "rejected"
 		payment.RefundStatus = &rejectedStatus
-		database.DB.Save(&payment)
+		result := database.DB.Model(&models.Payment{}).Where("id = ? AND refund_status IN ?", payment.ID, []string{"requested", "failed"}).Update("refund_status", rejectedStatus)
+		if result.Error != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to reject refund"})
+		}
+		if result.RowsAffected == 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "There is no open refund request for this payment"})
+		}
 
-		go notifications.SendEmail(payment.Booking.Student.FullName, payment.Booking.Student.Email, "Update on Your Refund Request", "<h1>Refund Request Update</h1><p>Your refund request has been reviewed and was not approved.</p>")
+		go notifications.SendEmail(student.FullName, student.Email, "Update on Your Refund Request", "<h1>Refund Request Update</h1><p>Your refund request has been reviewed and was not approved.</p>")
 	}
 
-	return c.JSON(fiber.Map{"message": "Refund request processed successfully"})
+	websocket.Publish(websocket.EventRefundDecided, fiber.Map{
+		"payment_id":    payment.ID,
+		"booking_id":    payment.BookingID,
+		"decision":      req.Decision,
+		"refund_status": payment.RefundStatus,
+		"refund":        refund,
+	}, student.ID)
+
+	return c.JSON(fiber.Map{"message": "Refund request processed successfully", "refund": refund})
+}
+
+// IssuePaymentRefund lets an admin refund any part of a payment outside the request flow,
+// for goodwill gestures or to retry a refund the provider turned down.
+func IssuePaymentRefund(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	adminID, _ := uuid.Parse(claims["user_id"].(string))
+
+	paymentID, err := uuid.Parse(c.Params("paymentId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payment ID format"})
+	}
+
+	type IssueRefundRequest struct {
+		Amount   float64 `json:"amount" validate:"omitempty,gt=0"`
+		RefundTo string  `json:"refund_to" validate:"omitempty,oneof=original credit"`
+		Reason   string  `json:"reason" validate:"required"`
+	}
+	var req IssueRefundRequest
+	if err := c.BodyParser(&req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"}) }
+	if err := validate.Struct(req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()}) }
+
+	refund, err := services.IssueRefund(paymentID, req.Amount, req.RefundTo == "credit", req.Reason, &adminID)
+	if err != nil { return refundErrorResponse(c, err) }
+
+	return c.Status(fiber.StatusCreated).JSON(refund)
+}
+
+func ListPaymentRefunds(c *fiber.Ctx) error {
+	var refunds []models.Refund
+	database.DB.Where("payment_id = ?", c.Params("paymentId")).Order("created_at desc").Find(&refunds)
+	return c.JSON(refunds)
 }
 
 
@@ -467,6 +663,13 @@ func ProcessPayoutRequest(c *fiber.Ctx) error {
 		)
 	}
 
+	websocket.Publish(websocket.EventPayoutDecided, fiber.Map{
+		"payout_request_id": payoutRequest.ID,
+		"decision":          req.Decision,
+		"amount":            payoutRequest.Amount,
+		"admin_notes":       req.AdminNotes,
+	}, payoutRequest.TeacherID)
+
 	return c.JSON(fiber.Map{"message": "Payout request processed."})
 }
 
@@ -522,7 +725,7 @@ func ToggleUserStatus(c *fiber.Ctx) error {
 type AdminBundleRequest struct {
 	Name            string  `json:"name" validate:"required"`
 	LanguageID      string  `json:"language_id" validate:"required,uuid"`
-	NumberOfClasses int     `json:"number_of_classes" validate:"required,gt=0"`
+	TotalMinutes    int     `json:"total_minutes" validate:"required,gt=0"`
 	Price           float64 `json:"price" validate:"required,gt=0"`
 }
 
@@ -544,7 +747,7 @@ func AdminCreateBundle(c *fiber.Ctx) error {
 	bundle := models.Bundle{
 		Name:            req.Name,
 		LanguageID:      uuid.MustParse(req.LanguageID),
-		NumberOfClasses: req.NumberOfClasses,
+		TotalMinutes:    req.TotalMinutes,
 		Price:           req.Price,
 		IsActive:        true,
 	}
@@ -568,7 +771,7 @@ func AdminUpdateBundle(c *fiber.Ctx) error {
 
 	bundle.Name = req.Name
 	bundle.LanguageID = uuid.MustParse(req.LanguageID)
-	bundle.NumberOfClasses = req.NumberOfClasses
+	bundle.TotalMinutes = req.TotalMinutes
 	bundle.Price = req.Price
 	database.DB.Save(&bundle)
 
@@ -646,6 +849,79 @@ func AdminGetPayments(c *fiber.Ctx) error {
 	})
 }
 
+// ResolvePaymentReview settles a payment held because the provider reported a different amount or
+// currency. Accepting fulfils it as paid; refunding cancels the purchase and queues a refund of what was paid.
+func ResolvePaymentReview(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	adminID, _ := uuid.Parse(claims["user_id"].(string))
+
+	paymentID, err := uuid.Parse(c.Params("paymentId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payment ID format"})
+	}
+
+	type ResolveRequest struct {
+		Decision string `json:"decision" validate:"required,oneof=accept refund"`
+		Note     string `json:"note"`
+	}
+	var req ResolveRequest
+	if err := c.BodyParser(&req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"}) }
+	if err := validate.Struct(req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()}) }
+
+	if req.Decision == "accept" {
+		note := "Payment accepted after review"
+		if req.Note != "" {
+			note += ": " + req.Note
+		}
+		outcome, err := services.AcceptReviewedPayment(paymentID, note)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Payment record not found"})
+		}
+		if errors.Is(err, services.ErrPaymentNotUnderReview) || errors.Is(err, services.ErrPaymentNotPayable) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil { return bookingTransitionResponse(c, err, "Failed to accept payment") }
+		return c.JSON(fiber.Map{"message": "Payment accepted", "outcome": outcome})
+	}
+
+	reason := "Payment amount did not match and was refunded after review"
+	if req.Note != "" {
+		reason = req.Note
+	}
+	payment, err := services.RejectReviewedPayment(paymentID, bookingstate.UserActor(adminID, "admin"), reason)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Payment record not found"})
+	}
+	if errors.Is(err, services.ErrPaymentNotUnderReview) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil { return bookingTransitionResponse(c, err, "Failed to reject payment") }
+
+	return c.JSON(fiber.Map{"message": "Purchase cancelled and refund queued", "payment": payment})
+}
+
+func AdminGetWebhookRejections(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.WebhookAuditLog{})
+	if provider := c.Query("provider"); provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+
+	var total int64
+	var entries []models.WebhookAuditLog
+	query.Count(&total)
+	query.Order("created_at desc").Offset(offset).Limit(limit).Find(&entries)
+
+	return c.JSON(fiber.Map{
+		"data": entries,
+		"meta": fiber.Map{ "total": total, "page": page, "last_page": int(math.Ceil(float64(total) / float64(limit))) },
+	})
+}
+
 
 func AdminGetReviews(c *fiber.Ctx) error {
 	var reviews []models.Review
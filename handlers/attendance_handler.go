@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	config "github.com/anjiri1684/language_tutor/configs"
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+func JoinClass(c *fiber.Ctx) error {
+	return checkIn(c, "join")
+}
+
+func LeaveClass(c *fiber.Ctx) error {
+	return checkIn(c, "leave")
+}
+
+func checkIn(c *fiber.Ctx, event string) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	userID, _ := uuid.Parse(claims["user_id"].(string))
+
+	var booking models.Booking
+	if err := database.DB.Preload("AvailabilitySlot").First(&booking, "id = ?", c.Params("bookingId")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Booking not found"})
+	}
+	if booking.Status != "confirmed" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Attendance can only be recorded for confirmed bookings"})
+	}
+
+	record, err := services.RecordAttendance(database.DB, booking, userID, event, "checkin", time.Now())
+	if err != nil {
+		return attendanceErrorResponse(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(record)
+}
+
+type MeetingWebhookPayload struct {
+	BookingID  string    `json:"booking_id"`
+	UserID     string    `json:"user_id"`
+	Email      string    `json:"email"`
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// HandleMeetingWebhook records join/leave events pushed by a video provider.
+// Providers authenticate with the shared MEETING_WEBHOOK_SECRET in the X-Webhook-Secret header.
+func HandleMeetingWebhook(c *fiber.Ctx) error {
+	secret := config.Config("MEETING_WEBHOOK_SECRET")
+	if secret == "" || subtle.ConstantTimeCompare([]byte(c.Get("X-Webhook-Secret")), []byte(secret)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid webhook secret"})
+	}
+
+	var payload MeetingWebhookPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse webhook payload"})
+	}
+	if payload.Event != "join" && payload.Event != "leave" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "event must be 'join' or 'leave'"})
+	}
+	if payload.OccurredAt.IsZero() {
+		payload.OccurredAt = time.Now()
+	}
+
+	var booking models.Booking
+	if err := database.DB.Preload("AvailabilitySlot").First(&booking, "id = ?", payload.BookingID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Booking not found"})
+	}
+
+	userID, err := uuid.Parse(payload.UserID)
+	if err != nil {
+		var user models.User
+		if err := database.DB.First(&user, "email = ?", strings.ToLower(strings.TrimSpace(payload.Email))).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Participant not found"})
+		}
+		userID = user.ID
+	}
+
+	source := "provider:" + c.Params("provider")
+	if _, err := services.RecordAttendance(database.DB, booking, userID, payload.Event, source, payload.OccurredAt); err != nil {
+		return attendanceErrorResponse(c, err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Attendance recorded"})
+}
+
+func attendanceErrorResponse(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotAParticipant):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrOutsideClassWindow):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to record attendance"})
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AvailabilityRuleRequest struct {
+	LanguageID     string   `json:"language_id" validate:"required,uuid"`
+	DaysOfWeek     []int    `json:"days_of_week" validate:"required,min=1,dive,min=0,max=6"`
+	StartLocalTime string   `json:"start_local_time" validate:"required,datetime=15:04"`
+	EndLocalTime   string   `json:"end_local_time" validate:"required,datetime=15:04"`
+	TimeZone       string   `json:"time_zone" validate:"required,timezone"`
+	EffectiveFrom  string   `json:"effective_from" validate:"required,datetime=2006-01-02"`
+	EffectiveUntil string   `json:"effective_until,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	Exceptions     []string `json:"exceptions,omitempty" validate:"omitempty,dive,datetime=2006-01-02"`
+	MaxStudents    int      `json:"max_students,omitempty"`
+}
+
+func applyAvailabilityRuleRequest(rule *models.AvailabilityRule, req AvailabilityRuleRequest) error {
+	if req.EndLocalTime <= req.StartLocalTime {
+		return errors.New("start time must be before end time")
+	}
+
+	loc, _ := time.LoadLocation(req.TimeZone)
+	effectiveFrom, _ := time.ParseInLocation("2006-01-02", req.EffectiveFrom, loc)
+	var effectiveUntil *time.Time
+	if req.EffectiveUntil != "" {
+		until, _ := time.ParseInLocation("2006-01-02", req.EffectiveUntil, loc)
+		until = until.AddDate(0, 0, 1).Add(-time.Second)
+		if until.Before(effectiveFrom) {
+			return errors.New("effective_until must not be before effective_from")
+		}
+		effectiveUntil = &until
+	}
+
+	days := make([]string, 0, len(req.DaysOfWeek))
+	for _, d := range req.DaysOfWeek {
+		days = append(days, strconv.Itoa(d))
+	}
+
+	maxStudents := 1
+	if req.MaxStudents > 1 {
+		maxStudents = req.MaxStudents
+	}
+
+	rule.LanguageID = uuid.MustParse(req.LanguageID)
+	rule.DaysOfWeek = strings.Join(days, ",")
+	rule.StartLocalTime = req.StartLocalTime
+	rule.EndLocalTime = req.EndLocalTime
+	rule.TimeZone = req.TimeZone
+	rule.EffectiveFrom = effectiveFrom
+	rule.EffectiveUntil = effectiveUntil
+	rule.Exceptions = strings.Join(req.Exceptions, ",")
+	rule.MaxStudents = maxStudents
+	return nil
+}
+
+// teachesLanguage reports whether the language is on the teacher's profile.
+func teachesLanguage(teacherID uuid.UUID, languageID string) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.TeacherLanguage{}).
+		Where("teacher_user_id = ? AND language_id = ?", teacherID, languageID).Count(&count).Error
+	return count > 0, err
+}
+
+func CreateAvailabilityRule(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	teacherID, _ := uuid.Parse(claims["user_id"].(string))
+
+	var req AvailabilityRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if teaches, err := teachesLanguage(teacherID, req.LanguageID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check your languages"})
+	} else if !teaches {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Add this language to your profile before scheduling it"})
+	}
+
+	rule := models.AvailabilityRule{TeacherID: teacherID, IsActive: true}
+	if err := applyAvailabilityRuleRequest(&rule, req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var created int
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rule).Error; err != nil { return err }
+
+		var err error
+		created, err = services.MaterializeRule(tx, rule, time.Now().AddDate(0, 0, 7*services.AvailabilityWeeksAhead()))
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create availability rule"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"rule":          rule,
+		"slots_created": created,
+	})
+}
+
+func GetMyAvailabilityRules(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	teacherID, _ := uuid.Parse(claims["user_id"].(string))
+
+	var rules []models.AvailabilityRule
+	database.DB.Preload("Language").Where("teacher_id = ?", teacherID).Order("created_at desc").Find(&rules)
+
+	return c.JSON(rules)
+}
+
+func UpdateAvailabilityRule(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	teacherID, _ := uuid.Parse(claims["user_id"].(string))
+	ruleID := c.Params("ruleId")
+
+	var req AvailabilityRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var rule models.AvailabilityRule
+	if err := database.DB.First(&rule, "id = ? AND teacher_id = ?", ruleID, teacherID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Availability rule not found"})
+	}
+	if teaches, err := teachesLanguage(teacherID, req.LanguageID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check your languages"})
+	} else if !teaches {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Add this language to your profile before scheduling it"})
+	}
+	if err := applyAvailabilityRuleRequest(&rule, req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var created int
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&rule).Error; err != nil { return err }
+		if err := services.RemoveUnbookedRuleSlots(tx, rule); err != nil { return err }
+
+		var err error
+		created, err = services.MaterializeRule(tx, rule, time.Now().AddDate(0, 0, 7*services.AvailabilityWeeksAhead()))
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update availability rule"})
+	}
+
+	return c.JSON(fiber.Map{
+		"rule":          rule,
+		"slots_created": created,
+	})
+}
+
+func DeleteAvailabilityRule(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	teacherID, _ := uuid.Parse(claims["user_id"].(string))
+	ruleID := c.Params("ruleId")
+
+	var rule models.AvailabilityRule
+	if err := database.DB.First(&rule, "id = ? AND teacher_id = ?", ruleID, teacherID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Availability rule not found"})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := services.RemoveUnbookedRuleSlots(tx, rule); err != nil { return err }
+		return tx.Delete(&rule).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete availability rule"})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
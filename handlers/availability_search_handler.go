@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSlotSearchLimit = 20
+	maxSlotSearchLimit     = 100
+
+	// slotPriceSQL prices each slot for its length from the teacher's (or the language's) per-session rate.
+	slotPriceSQL = "ROUND(COALESCE(tl.price_per_session, l.price_per_session) * EXTRACT(EPOCH FROM (s.end_time - s.start_time)) / 60 / l.session_minutes, 2)"
+)
+
+type SlotSearchResult struct {
+	models.AvailabilitySlot
+	TeacherID      uuid.UUID `json:"teacher_id"`
+	TeacherName    string    `json:"teacher_name"`
+	TeacherRating  float32   `json:"teacher_rating"`
+	Price          float64   `json:"price"`
+	Currency       string    `json:"currency"`
+	SeatsRemaining int       `json:"seats_remaining"`
+}
+
+type slotSearchRow struct {
+	ID          uuid.UUID
+	StartTime   time.Time
+	Price       float64
+	Currency    string
+	AvgRating   float32
+	TeacherName string
+}
+
+// slotSearchCursor is the last row of the previous page, encoded as opaque base64 JSON.
+type slotSearchCursor struct {
+	StartTime time.Time `json:"s"`
+	Price     float64   `json:"p"`
+	Rating    float32   `json:"r"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func encodeSlotSearchCursor(row slotSearchRow) string {
+	raw, _ := json.Marshal(slotSearchCursor{StartTime: row.StartTime, Price: row.Price, Rating: row.AvgRating, ID: row.ID})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeSlotSearchCursor(value string) (*slotSearchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor slotSearchCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+func SearchAvailability(c *fiber.Ctx) error {
+	loc := viewerLocation(c)
+
+	query := database.DB.Table("availability_slots AS s").
+		Select("s.id, s.start_time, "+slotPriceSQL+" AS price, COALESCE(tl.currency, l.currency) AS currency, t.avg_rating, u.full_name AS teacher_name").
+		Joins("JOIN teachers t ON t.user_id = s.teacher_id").
+		Joins("JOIN users u ON u.id = s.teacher_id").
+		Joins("JOIN languages l ON l.id = s.language_id").
+		Joins("LEFT JOIN teacher_languages tl ON tl.teacher_user_id = s.teacher_id AND tl.language_id = s.language_id").
+		Where("t.status = ? AND u.is_active = ?", "active", true).
+		Where("s.status = ? AND s.current_students < s.max_students AND s.start_time > ?", "available", time.Now())
+
+	languageID := c.Query("language_id")
+	if languageID != "" {
+		if _, err := uuid.Parse(languageID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid language_id"})
+		}
+		query = query.Where("s.language_id = ?", languageID)
+	}
+	// Languages are priced in different currencies, so prices only compare within one language.
+	if languageID == "" && (c.Query("min_price") != "" || c.Query("max_price") != "" || c.Query("sort") == "price") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "language_id is required to filter or sort by price"})
+	}
+
+	from, to, err := services.ParseLocalDateRange(c.Query("from"), c.Query("to"), loc)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if from != nil {
+		query = query.Where("s.start_time >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("s.start_time < ?", *to)
+	}
+
+	timeFrom, timeTo := c.Query("time_from"), c.Query("time_to")
+	if timeFrom != "" || timeTo != "" {
+		if timeFrom == "" {
+			timeFrom = "00:00"
+		}
+		if timeTo == "" {
+			timeTo = "23:59"
+		}
+		if _, err := time.Parse("15:04", timeFrom); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "time_from must be HH:MM"})
+		}
+		if _, err := time.Parse("15:04", timeTo); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "time_to must be HH:MM"})
+		}
+		localStart := "(s.start_time AT TIME ZONE ?)::time"
+		if timeFrom <= timeTo {
+			query = query.Where(localStart+" BETWEEN ?::time AND ?::time", loc.String(), timeFrom, timeTo)
+		} else {
+			// A window like 22:00-02:00 wraps past midnight.
+			query = query.Where("("+localStart+" >= ?::time OR "+localStart+" <= ?::time)", loc.String(), timeFrom, loc.String(), timeTo)
+		}
+	}
+
+	if minPrice := c.Query("min_price"); minPrice != "" {
+		value, err := strconv.ParseFloat(minPrice, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid min_price"})
+		}
+		query = query.Where(slotPriceSQL+" >= ?", value)
+	}
+	if maxPrice := c.Query("max_price"); maxPrice != "" {
+		value, err := strconv.ParseFloat(maxPrice, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid max_price"})
+		}
+		query = query.Where(slotPriceSQL+" <= ?", value)
+	}
+	if minRating := c.Query("min_rating"); minRating != "" {
+		value, err := strconv.ParseFloat(minRating, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid min_rating"})
+		}
+		query = query.Where("t.avg_rating >= ?", value)
+	}
+
+	switch c.Query("type") {
+	case "":
+	case "group":
+		query = query.Where("s.max_students > 1")
+	case "one_on_one":
+		query = query.Where("s.max_students = 1")
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "type must be group or one_on_one"})
+	}
+	if minSeats := c.Query("min_seats"); minSeats != "" {
+		value, err := strconv.Atoi(minSeats)
+		if err != nil || value < 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid min_seats"})
+		}
+		query = query.Where("s.max_students - s.current_students >= ?", value)
+	}
+
+	limit := c.QueryInt("limit", defaultSlotSearchLimit)
+	if limit < 1 || limit > maxSlotSearchLimit {
+		limit = defaultSlotSearchLimit
+	}
+
+	var cursor *slotSearchCursor
+	if rawCursor := c.Query("cursor"); rawCursor != "" {
+		if cursor, err = decodeSlotSearchCursor(rawCursor); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid cursor"})
+		}
+	}
+
+	sort := c.Query("sort", "start_time")
+	switch sort {
+	case "start_time":
+		if cursor != nil {
+			query = query.Where("(s.start_time, s.id) > (?, ?)", cursor.StartTime, cursor.ID)
+		}
+		query = query.Order("s.start_time asc, s.id asc")
+	case "price":
+		if cursor != nil {
+			query = query.Where("("+slotPriceSQL+", s.id) > (?, ?)", cursor.Price, cursor.ID)
+		}
+		query = query.Order(slotPriceSQL + " asc, s.id asc")
+	case "rating":
+		if cursor != nil {
+			query = query.Where("(t.avg_rating < ? OR (t.avg_rating = ? AND s.id > ?))", cursor.Rating, cursor.Rating, cursor.ID)
+		}
+		query = query.Order("t.avg_rating desc, s.id asc")
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "sort must be start_time, price or rating"})
+	}
+
+	var rows []slotSearchRow
+	if err := query.Limit(limit + 1).Scan(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to search availability"})
+	}
+
+	var nextCursor *string
+	if len(rows) > limit {
+		rows = rows[:limit]
+		next := encodeSlotSearchCursor(rows[len(rows)-1])
+		nextCursor = &next
+	}
+
+	ids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	var slots []models.AvailabilitySlot
+	if len(ids) > 0 {
+		database.DB.Preload("Language").Where("id IN ?", ids).Find(&slots)
+	}
+	slotsByID := make(map[uuid.UUID]models.AvailabilitySlot, len(slots))
+	for _, slot := range slots {
+		slotsByID[slot.ID] = slot
+	}
+
+	results := make([]SlotSearchResult, 0, len(rows))
+	for _, row := range rows {
+		slot, ok := slotsByID[row.ID]
+		if !ok {
+			continue
+		}
+		services.LocalizeSlot(&slot, loc)
+		results = append(results, SlotSearchResult{
+			AvailabilitySlot: slot,
+			TeacherID:        slot.TeacherID,
+			TeacherName:      row.TeacherName,
+			TeacherRating:    row.AvgRating,
+			Price:            row.Price,
+			Currency:         row.Currency,
+			SeatsRemaining:   slot.MaxStudents - slot.CurrentStudents,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"results":     results,
+		"next_cursor": nextCursor,
+	})
+}
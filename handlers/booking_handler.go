@@ -2,8 +2,8 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"log"
-	"math"
 	"strconv"
 	"time"
 
@@ -13,9 +13,12 @@ import (
 	"github.com/anjiri1684/language_tutor/notifications"
 	"github.com/anjiri1684/language_tutor/payments"
 	"github.com/anjiri1684/language_tutor/services"
+	"github.com/anjiri1684/language_tutor/services/bookingstate"
+	"github.com/anjiri1684/language_tutor/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -23,8 +26,41 @@ import (
 type CreateBookingRequest struct {
 	AvailabilitySlotID string `json:"availability_slot_id" validate:"required,uuid"`
 	UseCredit          bool   `json:"use_credit,omitempty"`
+	UseBundleID        string `json:"use_bundle_id,omitempty" validate:"omitempty,uuid"`
 	PaymentProvider    string `json:"payment_provider,omitempty"`
 	MpesaPhoneNumber   string `json:"mpesa_phone_number,omitempty"`
+	WaitlistClaimToken string `json:"waitlist_claim_token,omitempty"`
+	Trial              bool   `json:"trial,omitempty"`
+}
+
+var errTrialTaken = errors.New("You have already had a trial lesson with this teacher")
+
+// createBooking inserts a new booking. Trials are checked inside the booking's transaction, and the
+// idx_bookings_one_trial index catches a concurrent request that got past the check at the same time.
+func createBooking(tx *gorm.DB, booking *models.Booking) error {
+	if booking.IsTrial {
+		var previousTrials int64
+		err := tx.Model(&models.Booking{}).
+			Where("student_id = ? AND teacher_id = ? AND is_trial = ? AND status <> ?", booking.StudentID, booking.TeacherID, true, bookingstate.Cancelled).
+			Count(&previousTrials).Error
+		if err != nil { return err }
+		if previousTrials > 0 { return errTrialTaken }
+	}
+
+	err := tx.Create(booking).Error
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_bookings_one_trial" {
+		return errTrialTaken
+	}
+	return err
+}
+
+// takeSeat reserves a seat for a new booking, or converts a waitlist offer into one when a claim token is given.
+func takeSeat(tx *gorm.DB, slotID, studentID uuid.UUID, claimToken string) error {
+	if claimToken != "" {
+		return services.ClaimWaitlistSeat(tx, claimToken, studentID, slotID)
+	}
+	return services.ReserveSeat(tx, slotID)
 }
 
 func CreateBooking(c *fiber.Ctx) error {
@@ -49,55 +85,128 @@ func CreateBooking(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Availability slot has invalid language"})
  	}
 
+	rate, sessionCurrency := services.SessionPrice(database.DB, slot.TeacherID, slot.Language)
+	sessionMinutes := services.SlotMinutes(slot)
+	if req.Trial {
+		if req.UseBundleID != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Trial lessons cannot be paid for with a bundle"})
+		}
+
+		var teacher models.Teacher
+		if err := database.DB.First(&teacher, "user_id = ?", slot.TeacherID).Error; err != nil || !teacher.OffersTrial {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "This teacher does not offer trial lessons"})
+		}
+		if slot.MaxStudents > 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Trial lessons can only be booked on one-on-one slots"})
+		}
+		if slot.EndTime.Sub(slot.StartTime) < time.Duration(teacher.TrialDurationMinutes)*time.Minute {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "This slot is too short for a trial lesson"})
+		}
+
+		// A trial is the first TrialDurationMinutes of the slot, priced for that length before the discount.
+		sessionMinutes = teacher.TrialDurationMinutes
+		rate = services.TrialPrice(rate, teacher)
+	}
+	sessionPrice := services.DurationPrice(rate, slot.Language.SessionMinutes, sessionMinutes)
+
+	if req.UseBundleID != "" {
+		studentBundleID, _ := uuid.Parse(req.UseBundleID)
+
+		var confirmedBooking models.Booking
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			var studentBundle models.StudentBundle
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Bundle.Language").First(&studentBundle, "id = ? AND student_id = ?", studentBundleID, studentID).Error; err != nil {
+				return errors.New("bundle not found")
+			}
+			// An exhausted bundle can't cover a standard session but may still pay for a shorter one.
+			usable := studentBundle.Status == "active" || studentBundle.Status == "exhausted"
+			if !usable || studentBundle.RemainingMinutes < sessionMinutes {
+				return fmt.Errorf("this bundle does not have %d minutes remaining", sessionMinutes)
+			}
+			if slot.LanguageID == nil || studentBundle.Bundle.LanguageID != *slot.LanguageID {
+				return errors.New("this bundle cannot be used for a class in this language")
+			}
+
+			if err := takeSeat(tx, slotID, studentID, req.WaitlistClaimToken); err != nil { return err }
+
+			studentBundle.RemainingMinutes -= sessionMinutes
+			studentBundle.Status = services.BundleStatusFor(studentBundle)
+			if err := tx.Save(&studentBundle).Error; err != nil { return err }
+
+			confirmedBooking = models.Booking{
+				StudentID: studentID, TeacherID: slot.TeacherID, AvailabilitySlotID: slot.ID,
+				Price:           services.DurationPrice(studentBundle.Bundle.Price, studentBundle.Bundle.TotalMinutes, sessionMinutes),
+				DurationMinutes: sessionMinutes,
+				Currency:        studentBundle.Bundle.Currency,
+				StudentBundleID: &studentBundle.ID,
+				Status:          bookingstate.Confirmed,
+			}
+			if err := tx.Create(&confirmedBooking).Error; err != nil { return err }
+			if err := bookingstate.RecordInitial(tx, &confirmedBooking, bookingstate.UserActor(studentID, "student"), "Paid with class bundle"); err != nil { return err }
+
+			payment := models.Payment{
+				BookingID: &confirmedBooking.ID,
+				Amount:    0,
+				Currency:  studentBundle.Bundle.Currency,
+				Provider:  "bundle",
+				Status:    "succeeded",
+			}
+			if err := tx.Create(&payment).Error; err != nil { return err }
+			return nil
+		})
+		if err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()}) }
+
+		go services.SendBookingConfirmation(confirmedBooking.ID,
+			"<h1>Booking Confirmed</h1><p>Your class has been successfully booked using one of your bundle classes.</p>",
+			"<h1>New Booking</h1><p>A student has booked a session with you using their class bundle.</p>")
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"message": "Booking confirmed successfully using your class bundle.",
+			"booking": confirmedBooking,
+		})
+	}
+
 	if req.UseCredit {
 		var student models.User
 		if err := database.DB.First(&student, "id = ?", studentID).Error; err != nil {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Student not found"})
 		}
 
-		if student.CreditBalance >= slot.Language.PricePerSession {
+		if student.CreditBalance >= sessionPrice {
 			var confirmedBooking models.Booking
 			err := database.DB.Transaction(func(tx *gorm.DB) error {
-				if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&slot, "id = ?", slotID).Error; err != nil { return err }
-				
-				if slot.Status == "full" || slot.Status == "booked" || slot.CurrentStudents >= slot.MaxStudents {
-					return errors.New("this class is full or no longer available")
-				}
-				slot.CurrentStudents++
-				if slot.CurrentStudents >= slot.MaxStudents {
-					if slot.MaxStudents > 1 { slot.Status = "full" } else { slot.Status = "booked" }
-				}
+				if err := takeSeat(tx, slotID, studentID, req.WaitlistClaimToken); err != nil { return err }
 
-				student.CreditBalance -= slot.Language.PricePerSession
+				student.CreditBalance -= sessionPrice
 				if err := tx.Save(&student).Error; err != nil { return err }
-				if err := tx.Save(&slot).Error; err != nil { return err }
 
 				confirmedBooking = models.Booking{
 					StudentID: studentID, TeacherID: slot.TeacherID, AvailabilitySlotID: slot.ID,
-					Price: slot.Language.PricePerSession, 
-					Currency: slot.Language.Currency, 
-					Status: "confirmed",
+					Price: sessionPrice, 
+					DurationMinutes: sessionMinutes,
+					Currency: sessionCurrency, 
+					IsTrial: req.Trial,
+					Status: bookingstate.Confirmed,
 				}
-				if err := tx.Create(&confirmedBooking).Error; err != nil { return err }
+				if err := createBooking(tx, &confirmedBooking); err != nil { return err }
+				if err := bookingstate.RecordInitial(tx, &confirmedBooking, bookingstate.UserActor(studentID, "student"), "Paid with credit balance"); err != nil { return err }
 				
 				payment := models.Payment{
 					BookingID: &confirmedBooking.ID, 
 					Amount: confirmedBooking.Price, 
-					Currency: slot.Language.Currency, 
+					Currency: sessionCurrency, 
 					Provider: "credit", 
 					Status: "succeeded",
 				}
 				if err := tx.Create(&payment).Error; err != nil { return err }
-
-				go func() {
-					if err := tx.Preload("Student").Preload("Teacher").First(&confirmedBooking).Error; err == nil {
-						notifications.SendEmail(confirmedBooking.Student.FullName, confirmedBooking.Student.Email, "Your Booking is Confirmed!", "<h1>Booking Confirmed</h1><p>Your class has been successfully booked using your credit balance.</p>")
-						notifications.SendEmail(confirmedBooking.Teacher.FullName, confirmedBooking.Teacher.Email, "You Have a New Booking!", "<h1>New Booking</h1><p>A student has booked a session with you using their credit.</p>")
-					}
-				}()
 				return nil
 			})
+			if errors.Is(err, errTrialTaken) { return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()}) }
 			if err != nil { return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process credit payment: " + err.Error()}) }
+
+			go services.SendBookingConfirmation(confirmedBooking.ID,
+				"<h1>Booking Confirmed</h1><p>Your class has been successfully booked using your credit balance.</p>",
+				"<h1>New Booking</h1><p>A student has booked a session with you using their credit.</p>")
 			
 			return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 				"message": "Booking confirmed successfully using your credit balance.",
@@ -108,82 +217,46 @@ func CreateBooking(c *fiber.Ctx) error {
 		}
 	}
 
-	var price = slot.Language.PricePerSession
-	var currency = slot.Language.Currency
+	gateway, ok := payments.Get(req.PaymentProvider)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payment provider specified for external payment"})
+	}
 
-	if req.PaymentProvider == "mpesa" {
-		if currency != "KES" { 
-			kesPrice, err := services.ConvertUSDToKES(price)
-			if err != nil {
-				log.Printf("🔥 Currency conversion failed: %v", err)
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not perform currency conversion."})
-			}
-			price = math.Round(kesPrice) 
-			currency = "KES"
-		}
+	price, currency, err := services.ChargeAmount(gateway, sessionPrice, sessionCurrency)
+	if err != nil {
+		log.Printf("🔥 Currency conversion failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not perform currency conversion."})
 	}
 
 	var booking models.Booking
 	var payment models.Payment
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&slot, "id = ?", slotID).Error; err != nil { return err }
-		
-		if slot.Status == "full" || slot.Status == "booked" || slot.CurrentStudents >= slot.MaxStudents {
-			return errors.New("this class is full or no longer available")
-		}
-		slot.CurrentStudents++
-		if slot.CurrentStudents >= slot.MaxStudents {
-			if slot.MaxStudents > 1 { slot.Status = "full" } else { slot.Status = "booked" }
-		}
-		if err := tx.Save(&slot).Error; err != nil { return err }
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := takeSeat(tx, slotID, studentID, req.WaitlistClaimToken); err != nil { return err }
 
 		booking = models.Booking{
 			StudentID: studentID, TeacherID: slot.TeacherID, AvailabilitySlotID: slot.ID,
-			Price: slot.Language.PricePerSession, Currency: slot.Language.Currency, Status: "pending_payment",
+			Price: sessionPrice, DurationMinutes: sessionMinutes, Currency: sessionCurrency, IsTrial: req.Trial, Status: bookingstate.PendingPayment,
 		}
-		if err := tx.Create(&booking).Error; err != nil { return err }
+		if err := createBooking(tx, &booking); err != nil { return err }
+		if err := bookingstate.RecordInitial(tx, &booking, bookingstate.UserActor(studentID, "student"), "Awaiting "+gateway.Name()+" payment"); err != nil { return err }
 
 		payment = models.Payment{
 			BookingID: &booking.ID, Amount: price, Currency: currency,
-			Provider: req.PaymentProvider, Status: "pending",
+			Provider: gateway.Name(), Status: "pending",
 		}
 		if err := tx.Create(&payment).Error; err != nil { return err }
 		return nil
 	})
 	
+	if errors.Is(err, errTrialTaken) { return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()}) }
 	if err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()}) }
-	
-	if req.PaymentProvider == "mpesa" {
-		if req.MpesaPhoneNumber == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "M-Pesa phone number is required"})
-		}
-		
-		stkResponse, err := payments.InitiateMpesaSTKPush(price, req.MpesaPhoneNumber, payment.ID.String())
-		if err != nil {
-			log.Printf("🔥 CRITICAL: InitiateMpesaSTKPush failed: %v", err)
-			if err.Error() == "invalid M-Pesa phone number format" {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-			}
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Payment could not be initiated, please try again."})
-		}
-		
-		payment.MerchantRequestID = &stkResponse.Response.MerchantRequestID
-		database.DB.Save(&payment)
 
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-			"booking":          booking,
-			"customer_message": stkResponse.Response.CustomerMessage,
-		})
-	}
-	
-	if req.PaymentProvider == "paypal" {
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"booking": booking, "payment_id": payment.ID})
-	}
+	result, err := initiatePayment(gateway, &payment, req.MpesaPhoneNumber, "Language class booking")
+	if err != nil { return initiatePaymentErrorResponse(c, gateway, err) }
 
-	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payment provider specified for external payment"})
+	return c.Status(fiber.StatusCreated).JSON(paymentResponse(fiber.Map{"booking": booking}, payment, result))
 }
 
-
 type ReviewRequest struct {
 	Rating  int    `json:"rating" validate:"required,min=1,max=5"`
 	Comment string `json:"comment"`
@@ -278,21 +351,13 @@ func MarkBookingAsComplete(c *fiber.Ctx) error {
 	}
 
 	err := database.DB.Transaction(func(tx *gorm.DB) error {
-		booking.Status = "completed"
-		if err := tx.Save(&booking).Error; err != nil {
+		if err := bookingstate.Transition(tx, &booking, bookingstate.Completed, bookingstate.UserActor(teacherID, "teacher"), "Teacher marked the class as complete"); err != nil {
 			return err
 		}
 
-		commissionRate, _ := strconv.ParseFloat(config.Config("PLATFORM_COMMISSION_RATE"), 64)
-		earnings := booking.Price * (1 - commissionRate)
-
-		if err := tx.Model(&models.Teacher{}).Where("user_id = ?", booking.TeacherID).Update("current_balance", gorm.Expr("current_balance + ?", earnings)).Error; err != nil {
-			return err
-		}
-		
-		return nil
+		return services.CreditTeacherEarnings(tx, booking)
 	})
-	if err != nil { return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to complete booking"}) }
+	if err != nil { return bookingTransitionResponse(c, err, "Failed to complete booking") }
 
 	go services.AwardRewardsForClassCompletion(booking.StudentID)
 	go services.CheckAndGenerateCertificate(booking)
@@ -367,21 +432,239 @@ func RequestRefund(c *fiber.Ctx) error {
 	}
 
 	var payment models.Payment
-	database.DB.First(&payment, "booking_id = ?", bookingID)
-	
-	refundStatus := "requested"
-	payment.RefundStatus = &refundStatus
-	payment.RefundReason = &req.Reason
-	database.DB.Save(&payment)
+	if err := database.DB.First(&payment, "booking_id = ?", bookingID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No payment was found for this booking"})
+	}
+	if payment.Status != "succeeded" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Only paid bookings that have not been refunded can be refunded"})
+	}
+
+	// Conditional so an approved, in-flight or already open request cannot be reopened.
+	result := database.DB.Model(&models.Payment{}).
+		Where("id = ? AND status = ? AND (refund_status IS NULL OR refund_status NOT IN ?)", payment.ID, "succeeded", []string{"approved", "processing", "requested"}).
+		Updates(map[string]interface{}{"refund_status": "requested", "refund_reason": req.Reason})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to submit refund request"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "A refund has already been requested or issued for this booking"})
+	}
 
-	
 	return c.JSON(fiber.Map{"message": "Refund request submitted successfully. An admin will review it shortly."})
 }
 
 
+type CancelBookingRequest struct {
+	Reason   string `json:"reason"`
+	RefundTo string `json:"refund_to,omitempty" validate:"omitempty,oneof=credit original"`
+}
+
+func CancelBooking(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	studentID, _ := uuid.Parse(claims["user_id"].(string))
+	bookingID := c.Params("bookingId")
+
+	var req CancelBookingRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"}) }
+	}
+	if err := validate.Struct(req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()}) }
+	if req.RefundTo == "" {
+		req.RefundTo = "credit"
+	}
+
+	var booking models.Booking
+	if err := database.DB.Preload("AvailabilitySlot").Preload("Student").Preload("Teacher").First(&booking, "id = ?", bookingID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Booking not found"})
+	}
+	if booking.StudentID != studentID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "This is not your booking"})
+	}
+	if booking.AvailabilitySlot.StartTime.Before(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot cancel a class that has already started or finished"})
+	}
+
+	reason := "Cancelled by student"
+	if req.Reason != "" {
+		reason = reason + ": " + req.Reason
+	}
+
+	var result services.CancellationResult
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		refundPercent := services.RefundPercentFor(booking.AvailabilitySlot.StartTime, time.Now())
+		var err error
+		result, err = services.CancelBooking(tx, &booking, bookingstate.UserActor(studentID, "student"), reason, refundPercent, req.RefundTo)
+		return err
+	})
+	if err != nil { return bookingTransitionResponse(c, err, "Failed to cancel booking") }
+
+	classTime := services.ClassTimeForBoth(booking.AvailabilitySlot.StartTime, booking.Student, booking.Teacher)
+	var refundLine string
+	switch {
+	case result.NothingCharged:
+		refundLine = "No payment had been taken for this class, so nothing was charged."
+	case result.RefundedTo == "bundle":
+		refundLine = fmt.Sprintf("%d minutes have been returned to your bundle.", result.RefundMinutes)
+	case result.RefundAmount > 0 && result.RefundedTo == "credit":
+		refundLine = fmt.Sprintf("%.2f %s has been added to your credit balance.", result.RefundAmount, result.Currency)
+	case result.RefundAmount > 0:
+		refundLine = fmt.Sprintf("A refund of %.2f %s to your original payment method is being processed.", result.RefundAmount, result.Currency)
+	default:
+		refundLine = "Under our cancellation policy this cancellation is not eligible for a refund."
+	}
+	go func() {
+		notifications.SendEmail(booking.Student.FullName, booking.Student.Email, "Your Booking has been Cancelled", fmt.Sprintf("<h1>Booking Cancelled</h1><p>Your class on %s has been cancelled.</p><p>%s</p>", classTime, refundLine))
+		notifications.SendEmail(booking.Teacher.FullName, booking.Teacher.Email, "A Booking has been Cancelled", fmt.Sprintf("<h1>Booking Cancelled</h1><p>%s has cancelled their class with you on %s. The time slot is open for booking again.</p>", booking.Student.FullName, classTime))
+	}()
+
+	return c.JSON(fiber.Map{
+		"message":      "Booking cancelled successfully.",
+		"cancellation": result,
+	})
+}
+
+
+type TeacherCancelBookingRequest struct {
+	Reason   string `json:"reason" validate:"required,min=5"`
+	RefundTo string `json:"refund_to,omitempty" validate:"omitempty,oneof=credit original"`
+}
+
+func TeacherCancelBooking(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	teacherID, _ := uuid.Parse(claims["user_id"].(string))
+	bookingID := c.Params("bookingId")
+
+	var req TeacherCancelBookingRequest
+	if err := c.BodyParser(&req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"}) }
+	if err := validate.Struct(req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()}) }
+	if req.RefundTo == "" {
+		req.RefundTo = "original"
+	}
+
+	var booking models.Booking
+	if err := database.DB.Preload("AvailabilitySlot").Preload("Student").Preload("Teacher").First(&booking, "id = ?", bookingID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Booking not found"})
+	}
+	if booking.TeacherID != teacherID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not the teacher for this booking"})
+	}
+	if booking.AvailabilitySlot.StartTime.Before(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot cancel a class that has already started or finished"})
+	}
+
+	// An unpaid reservation costs the student nothing, so there is no compensation and it does not
+	// count against the teacher's reliability.
+	wasPaid := booking.Status != bookingstate.PendingPayment
+	var goodwillCredit float64
+	if wasPaid {
+		goodwillCredit, _ = strconv.ParseFloat(config.Config("TEACHER_CANCELLATION_GOODWILL_CREDIT"), 64)
+	}
+
+	var result services.CancellationResult
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = services.CancelBooking(tx, &booking, bookingstate.UserActor(teacherID, "teacher"), "Cancelled by teacher: "+req.Reason, 100, req.RefundTo)
+		if err != nil { return err }
+
+		if !wasPaid {
+			return nil
+		}
+		if goodwillCredit > 0 {
+			if err := services.AddCredit(tx, booking.StudentID, goodwillCredit); err != nil { return err }
+		}
+
+		return tx.Model(&models.Teacher{}).Where("user_id = ?", teacherID).Update("cancellation_count", gorm.Expr("cancellation_count + 1")).Error
+	})
+	if err != nil { return bookingTransitionResponse(c, err, "Failed to cancel booking") }
+
+	classTime := services.ClassTimeForBoth(booking.AvailabilitySlot.StartTime, booking.Student, booking.Teacher)
+	var refundLine string
+	switch {
+	case result.RefundedTo == "bundle":
+		refundLine = fmt.Sprintf("%d minutes have been returned to your bundle.", result.RefundMinutes)
+	case result.RefundAmount > 0 && result.RefundedTo == "credit":
+		refundLine = fmt.Sprintf("A full refund of %.2f %s has been added to your credit balance.", result.RefundAmount, result.Currency)
+	case result.RefundAmount > 0:
+		refundLine = fmt.Sprintf("A full refund of %.2f %s to your original payment method is being processed.", result.RefundAmount, result.Currency)
+	}
+	if !wasPaid {
+		refundLine = "No payment had been taken for this class, so nothing was charged."
+	}
+	if goodwillCredit > 0 {
+		refundLine += fmt.Sprintf(" We have also added a goodwill credit of %.2f to your account for the inconvenience.", goodwillCredit)
+	}
+	teacherLine := "the student has been fully refunded"
+	message := "Booking cancelled and the student has been refunded."
+	if !wasPaid {
+		teacherLine = "the student had not paid yet, so no refund was needed"
+		message = "Unpaid booking cancelled."
+	}
+	go func() {
+		notifications.SendEmail(booking.Student.FullName, booking.Student.Email, "Your Teacher has Cancelled a Class", fmt.Sprintf("<h1>Class Cancelled</h1><p>Unfortunately %s had to cancel your class on %s.</p><p><b>Reason:</b> %s</p><p>%s</p>", booking.Teacher.FullName, classTime, req.Reason, refundLine))
+		notifications.SendEmail(booking.Teacher.FullName, booking.Teacher.Email, "Class Cancellation Confirmed", fmt.Sprintf("<h1>Class Cancelled</h1><p>Your class with %s on %s has been cancelled and %s.</p>", booking.Student.FullName, classTime, teacherLine))
+	}()
+
+	return c.JSON(fiber.Map{
+		"message":         message,
+		"cancellation":    result,
+		"goodwill_credit": goodwillCredit,
+	})
+}
+
+func GetCancellationPolicy(c *fiber.Ctx) error {
+	return c.JSON(services.CancellationPolicy())
+}
+
+
 type RescheduleRequest struct {
-	NewStartTime string `json:"new_start_time" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
-	NewEndTime   string `json:"new_end_time" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	NewSlotID string `json:"new_slot_id" validate:"required,uuid"`
+}
+
+func findRescheduleSlot(booking models.Booking, slotID uuid.UUID) (models.AvailabilitySlot, error) {
+	var slot models.AvailabilitySlot
+	if err := database.DB.First(&slot, "id = ? AND teacher_id = ?", slotID, booking.TeacherID).Error; err != nil {
+		return slot, errors.New("the selected slot does not belong to this teacher")
+	}
+	if slot.ID == booking.AvailabilitySlotID {
+		return slot, errors.New("the selected slot is the one already booked")
+	}
+	if !slot.StartTime.After(time.Now()) {
+		return slot, errors.New("the selected slot is in the past")
+	}
+	if slot.Status != "available" || slot.CurrentStudents >= slot.MaxStudents {
+		return slot, services.ErrSlotUnavailable
+	}
+
+	var currentSlot models.AvailabilitySlot
+	if err := database.DB.First(&currentSlot, "id = ?", booking.AvailabilitySlotID).Error; err != nil {
+		return slot, err
+	}
+	if currentSlot.LanguageID != nil && (slot.LanguageID == nil || *slot.LanguageID != *currentSlot.LanguageID) {
+		return slot, errors.New("the selected slot is for a different language")
+	}
+	// The booking was priced for its length, so it can only move to a slot that fits it.
+	if services.SlotMinutes(slot) < booking.DurationMinutes {
+		return slot, errors.New("the selected slot is shorter than the booked class")
+	}
+	return slot, nil
+}
+
+func setRescheduleProposal(tx *gorm.DB, booking *models.Booking, slotID *uuid.UUID, proposedBy *string) error {
+	booking.ProposedSlotID = slotID
+	booking.ProposedBy = proposedBy
+	return tx.Model(&models.Booking{}).Where("id = ?", booking.ID).Updates(map[string]interface{}{
+		"proposed_slot_id": slotID,
+		"proposed_by":      proposedBy,
+	}).Error
+}
+
+func rescheduleErrorResponse(c *fiber.Ctx, err error, fallback string) error {
+	if errors.Is(err, services.ErrSlotUnavailable) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "The proposed slot is no longer available"})
+	}
+	return bookingTransitionResponse(c, err, fallback)
 }
 
 func RequestReschedule(c *fiber.Ctx) error {
@@ -395,29 +678,88 @@ func RequestReschedule(c *fiber.Ctx) error {
 	if err := validate.Struct(req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()}) }
 
 	var booking models.Booking
-	if err := database.DB.Preload("Teacher").First(&booking, "id = ?", bookingID).Error; err != nil {
+	if err := database.DB.Preload("Student").Preload("Teacher").First(&booking, "id = ?", bookingID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Booking not found"})
 	}
 	if booking.StudentID != studentID {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "This is not your booking"})
 	}
 
-	newStartTime, _ := time.Parse(time.RFC3339, req.NewStartTime)
-	newEndTime, _ := time.Parse(time.RFC3339, req.NewEndTime)
-	if newStartTime.Before(time.Now()) {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Proposed reschedule time cannot be in the past"})
+	newSlot, err := findRescheduleSlot(booking, uuid.MustParse(req.NewSlotID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	booking.Status = "reschedule_requested"
-	booking.ProposedStartTime = &newStartTime
-	booking.ProposedEndTime = &newEndTime
-	database.DB.Save(&booking)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := bookingstate.Transition(tx, &booking, bookingstate.RescheduleRequested, bookingstate.UserActor(studentID, "student"), "Student requested a new time"); err != nil { return err }
+		proposedBy := "student"
+		return setRescheduleProposal(tx, &booking, &newSlot.ID, &proposedBy)
+	})
+	if err != nil { return bookingTransitionResponse(c, err, "Failed to request reschedule") }
 
-	go notifications.SendEmail(booking.Teacher.FullName, booking.Teacher.Email, "Reschedule Request", "A student has requested to reschedule a class. Please log in to your dashboard to approve or deny the request.")
+	go notifications.SendEmail(booking.Teacher.FullName, booking.Teacher.Email, "Reschedule Request", fmt.Sprintf("A student has requested to move a class to %s. Please log in to your dashboard to approve, decline or propose another time.", services.ClassTimeForBoth(newSlot.StartTime, booking.Student, booking.Teacher)))
+	websocket.Publish(websocket.EventRescheduleRequested, fiber.Map{
+		"booking_id":       booking.ID,
+		"proposed_slot_id": newSlot.ID,
+		"proposed_start":   newSlot.StartTime,
+		"proposed_by":      "student",
+	}, booking.TeacherID)
 
 	return c.JSON(fiber.Map{"message": "Reschedule request sent to the teacher."})
 }
 
+func RespondToRescheduleProposal(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	studentID, _ := uuid.Parse(claims["user_id"].(string))
+	bookingID := c.Params("bookingId")
+
+	type RespondRequest struct {
+		Decision string `json:"decision" validate:"required,oneof=accept reject"`
+	}
+	var req RespondRequest
+	if err := c.BodyParser(&req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"}) }
+	if err := validate.Struct(req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()}) }
+
+	var booking models.Booking
+	if err := database.DB.Preload("Teacher").First(&booking, "id = ?", bookingID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Booking not found"})
+	}
+	if booking.StudentID != studentID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "This is not your booking"})
+	}
+	if booking.ProposedSlotID == nil || booking.ProposedBy == nil || *booking.ProposedBy != "teacher" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "There is no proposal from the teacher to respond to"})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		actor := bookingstate.UserActor(studentID, "student")
+		if req.Decision == "accept" {
+			if err := services.MoveBookingToSlot(tx, &booking, *booking.ProposedSlotID); err != nil { return err }
+			if err := bookingstate.Transition(tx, &booking, bookingstate.Confirmed, actor, "Student accepted the teacher's proposed time"); err != nil { return err }
+		} else {
+			if err := bookingstate.Transition(tx, &booking, bookingstate.Confirmed, actor, "Student declined the teacher's proposed time"); err != nil { return err }
+		}
+		return setRescheduleProposal(tx, &booking, nil, nil)
+	})
+	if err != nil { return rescheduleErrorResponse(c, err, "Failed to respond to reschedule proposal") }
+
+	websocket.Publish(websocket.EventRescheduleProcessed, fiber.Map{
+		"booking_id": booking.ID,
+		"decision":   req.Decision,
+		"status":     booking.Status,
+	}, booking.TeacherID)
+
+	if req.Decision == "accept" {
+		go services.RegenerateMeetingLink(booking.ID)
+		go notifications.SendEmail(booking.Teacher.FullName, booking.Teacher.Email, "Reschedule Accepted", "The student has accepted your proposed time and the class has been moved.")
+	} else {
+		go notifications.SendEmail(booking.Teacher.FullName, booking.Teacher.Email, "Reschedule Declined", "The student has declined your proposed time. The class stays at its original time.")
+	}
+
+	return c.JSON(fiber.Map{"message": "Reschedule response recorded successfully"})
+}
+
 
 func GetMyBookings(c *fiber.Ctx) error {
 	token := c.Locals("user").(*jwt.Token)
@@ -428,11 +770,13 @@ func GetMyBookings(c *fiber.Ctx) error {
 	database.DB.
 		Preload("Teacher.User").
 		Preload("AvailabilitySlot.Language").
+		Preload("ProposedSlot").
 		Where("student_id = ?", studentID).
 		Order("availability_slots.start_time desc").
 		Joins("JOIN availability_slots on bookings.availability_slot_id = availability_slots.id").
 		Find(&bookings)
 
+	localizeBookings(bookings, viewerLocation(c))
 	return c.JSON(bookings)
 }
 
@@ -450,5 +794,37 @@ func GetMyTeacherBookings(c *fiber.Ctx) error {
 		Joins("JOIN availability_slots on bookings.availability_slot_id = availability_slots.id").
 		Find(&bookings)
 
+	localizeBookings(bookings, viewerLocation(c))
 	return c.JSON(bookings)
-}
\ No newline at end of file
+}
+
+
+func bookingTransitionResponse(c *fiber.Ctx, err error, fallback string) error {
+	var transitionErr *bookingstate.TransitionError
+	var unknownErr *bookingstate.UnknownStatusError
+	if errors.As(err, &transitionErr) || errors.As(err, &unknownErr) || errors.Is(err, bookingstate.ErrStatusChanged) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fallback})
+}
+
+func GetBookingStatusHistory(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	userID, _ := uuid.Parse(claims["user_id"].(string))
+	role := claims["role"].(string)
+	bookingID := c.Params("bookingId")
+
+	var booking models.Booking
+	if err := database.DB.First(&booking, "id = ?", bookingID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Booking not found"})
+	}
+	if role != "admin" && booking.StudentID != userID && booking.TeacherID != userID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You do not have access to this booking's history."})
+	}
+
+	var history []models.BookingStatusHistory
+	database.DB.Where("booking_id = ?", booking.ID).Order("created_at asc").Find(&history)
+
+	return c.JSON(history)
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"log"
-	"math"
 	"time"
 
 	"github.com/anjiri1684/language_tutor/database"
@@ -19,7 +18,7 @@ import (
 type BundleRequest struct {
 	Name            string  `json:"name" validate:"required"`
 	LanguageID      string  `json:"language_id" validate:"required,uuid"`
-	NumberOfClasses int     `json:"number_of_classes" validate:"required,gt=0"`
+	TotalMinutes    int     `json:"total_minutes" validate:"required,gt=0"`
 	Price           float64 `json:"price" validate:"required,gt=0"`
 }
 
@@ -36,7 +35,7 @@ func CreateBundle(c *fiber.Ctx) error {
 	bundle := models.Bundle{
 		Name:            req.Name,
 		LanguageID:      uuid.MustParse(req.LanguageID),
-		NumberOfClasses: req.NumberOfClasses,
+		TotalMinutes:    req.TotalMinutes,
 		Price:           req.Price,
 	}
 
@@ -59,7 +58,7 @@ func UpdateBundle(c *fiber.Ctx) error {
 
 	bundle.Name = req.Name
 	bundle.LanguageID = uuid.MustParse(req.LanguageID)
-	bundle.NumberOfClasses = req.NumberOfClasses
+	bundle.TotalMinutes = req.TotalMinutes
 	bundle.Price = req.Price
 	database.DB.Save(&bundle)
 
@@ -120,7 +119,7 @@ func PurchaseBundle(c *fiber.Ctx) error {
 
 				activeBundle = models.StudentBundle{
 					StudentID: studentID, BundleID: bundle.ID, PurchaseDate: time.Now(),
-					RemainingClasses: bundle.NumberOfClasses, Status: "active",
+					RemainingMinutes: bundle.TotalMinutes, Status: "active",
 				}
 				if err := tx.Create(&activeBundle).Error; err != nil { return err }
 				
@@ -147,19 +146,15 @@ func PurchaseBundle(c *fiber.Ctx) error {
 		}
 	}
 
-	var price = bundle.Price
-	var currency = bundle.Currency
-
-	if req.PaymentProvider == "mpesa" {
-		if currency != "KES" {
-			kesPrice, err := services.ConvertUSDToKES(price)
-			if err != nil {
-				log.Printf("🔥 Currency conversion failed: %v", err)
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not perform currency conversion."})
-			}
-			price = math.Round(kesPrice) 
-			currency = "KES"
-		}
+	gateway, ok := payments.Get(req.PaymentProvider)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payment provider"})
+	}
+
+	price, currency, err := services.ChargeAmount(gateway, bundle.Price, bundle.Currency)
+	if err != nil {
+		log.Printf("🔥 Currency conversion failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not perform currency conversion."})
 	}
 
 	var studentBundle models.StudentBundle
@@ -170,7 +165,7 @@ func PurchaseBundle(c *fiber.Ctx) error {
 			StudentID:        studentID,
 			BundleID:         bundle.ID,
 			PurchaseDate:     time.Now(),
-			RemainingClasses: bundle.NumberOfClasses,
+			RemainingMinutes: bundle.TotalMinutes,
 			Status:           "pending_payment",
 		}
 		if err := tx.Create(&studentBundle).Error; err != nil { return err }
@@ -179,7 +174,7 @@ func PurchaseBundle(c *fiber.Ctx) error {
 			StudentBundleID: &studentBundle.ID,
 			Amount:          price,
 			Currency:        currency, 
-			Provider:        req.PaymentProvider,
+			Provider:        gateway.Name(),
 			Status:          "pending",
 		}
 		if err := tx.Create(&payment).Error; err != nil { return err }
@@ -187,37 +182,10 @@ func PurchaseBundle(c *fiber.Ctx) error {
 	})
 	if err != nil { return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create purchase records"}) }
 
-	if req.PaymentProvider == "mpesa" {
-		if req.MpesaPhoneNumber == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "M-Pesa phone number is required"})
-		}
-
-		stkResponse, err := payments.InitiateMpesaSTKPush(price, req.MpesaPhoneNumber, payment.ID.String())
-		if err != nil {
-			log.Printf("🔥 CRITICAL: InitiateMpesaSTKPush failed: %v", err)
-			if err.Error() == "invalid M-Pesa phone number format" {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-			}
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Payment could not be initiated, please try again."})
-		}
-
-		payment.MerchantRequestID = &stkResponse.Response.MerchantRequestID
-		database.DB.Save(&payment) 
-
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-			"student_bundle":   studentBundle,
-			"customer_message": stkResponse.Response.CustomerMessage,
-		})
-	}
-
-	if req.PaymentProvider == "paypal" {
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-			"student_bundle": studentBundle,
-			"payment_id":     payment.ID,
-		})
-	}
+	result, err := initiatePayment(gateway, &payment, req.MpesaPhoneNumber, "Class bundle purchase")
+	if err != nil { return initiatePaymentErrorResponse(c, gateway, err) }
 
-	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid payment provider"})
+	return c.Status(fiber.StatusCreated).JSON(paymentResponse(fiber.Map{"student_bundle": studentBundle}, payment, result))
 }
 
 
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/services"
+	"github.com/anjiri1684/language_tutor/services/bookingstate"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+func GetCalendarFeed(c *fiber.Ctx) error {
+	token := strings.TrimSuffix(c.Params("token"), ".ics")
+	if token == "" {
+		return c.Status(fiber.StatusNotFound).SendString("Calendar not found")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, "calendar_token = ?", token).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Calendar not found")
+	}
+
+	var bookings []models.Booking
+	database.DB.
+		Preload("Student").
+		Preload("Teacher").
+		Preload("AvailabilitySlot.Language").
+		Joins("JOIN availability_slots ON bookings.availability_slot_id = availability_slots.id").
+		Where("(bookings.student_id = ? OR bookings.teacher_id = ?) AND bookings.status = ?", user.ID, user.ID, bookingstate.Confirmed).
+		Order("availability_slots.start_time asc").
+		Find(&bookings)
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="classes.ics"`)
+	return c.Send(services.BuildCalendar("Class Learning", "", bookings, user))
+}
+
+func calendarFeedURL(c *fiber.Ctx, token string) string {
+	return c.BaseURL() + "/api/v1/calendar/" + token + ".ics"
+}
+
+func newCalendarToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(tokenBytes), nil
+}
+
+func GetMyCalendarFeed(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	userID, _ := uuid.Parse(claims["user_id"].(string))
+
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	if user.CalendarToken == nil {
+		calendarToken, err := newCalendarToken()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate calendar token"})
+		}
+		if err := database.DB.Model(&user).Update("calendar_token", calendarToken).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save calendar token"})
+		}
+		user.CalendarToken = &calendarToken
+	}
+
+	return c.JSON(fiber.Map{"feed_url": calendarFeedURL(c, *user.CalendarToken)})
+}
+
+func RotateMyCalendarFeed(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	userID, _ := uuid.Parse(claims["user_id"].(string))
+
+	calendarToken, err := newCalendarToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate calendar token"})
+	}
+	if err := database.DB.Model(&models.User{}).Where("id = ?", userID).Update("calendar_token", calendarToken).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save calendar token"})
+	}
+
+	return c.JSON(fiber.Map{"feed_url": calendarFeedURL(c, calendarToken)})
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/notifications"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type VocabularyItemRequest struct {
+	Term        string `json:"term" validate:"required,max=255"`
+	Translation string `json:"translation" validate:"max=255"`
+	Example     string `json:"example"`
+}
+
+type SkillRatingRequest struct {
+	Skill string `json:"skill" validate:"required,oneof=speaking listening reading writing grammar vocabulary pronunciation"`
+	Level string `json:"level" validate:"required,oneof=A1 A2 B1 B2 C1 C2"`
+}
+
+type HomeworkRequest struct {
+	Title        string    `json:"title" validate:"required,max=255"`
+	Instructions string    `json:"instructions"`
+	DueAt        time.Time `json:"due_at" validate:"required"`
+}
+
+type LessonReportRequest struct {
+	Summary      string                  `json:"summary"`
+	Topics       []string                `json:"topics" validate:"dive,required,max=255"`
+	Vocabulary   []VocabularyItemRequest `json:"vocabulary" validate:"dive"`
+	SkillRatings []SkillRatingRequest    `json:"skill_ratings" validate:"unique=Skill,dive"`
+	Homework     []HomeworkRequest       `json:"homework" validate:"dive"`
+}
+
+// SaveLessonReport creates or replaces the report for a completed class.
+// Topics, vocabulary and skill ratings are replaced; homework items are always added as new assignments.
+func SaveLessonReport(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	teacherID, _ := uuid.Parse(claims["user_id"].(string))
+	bookingID := c.Params("bookingId")
+
+	var req LessonReportRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	for _, hw := range req.Homework {
+		if !hw.DueAt.After(time.Now()) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Homework due dates must be in the future"})
+		}
+	}
+
+	var booking models.Booking
+	if err := database.DB.Preload("Student").First(&booking, "id = ?", bookingID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Booking not found"})
+	}
+	if booking.TeacherID != teacherID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not the teacher for this booking"})
+	}
+	if booking.Status != "completed" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Lesson reports can only be written for completed bookings"})
+	}
+
+	var report models.LessonReport
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.First(&report, "booking_id = ?", booking.ID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			report = models.LessonReport{BookingID: booking.ID, TeacherID: booking.TeacherID, StudentID: booking.StudentID}
+		} else if err != nil {
+			return err
+		}
+
+		report.Summary = req.Summary
+		if err := tx.Save(&report).Error; err != nil { return err }
+
+		if err := tx.Where("lesson_report_id = ?", report.ID).Delete(&models.LessonTopic{}).Error; err != nil { return err }
+		if err := tx.Where("lesson_report_id = ?", report.ID).Delete(&models.LessonVocabularyItem{}).Error; err != nil { return err }
+		if err := tx.Where("lesson_report_id = ?", report.ID).Delete(&models.LessonSkillRating{}).Error; err != nil { return err }
+
+		for _, topic := range req.Topics {
+			if err := tx.Create(&models.LessonTopic{LessonReportID: report.ID, Topic: strings.TrimSpace(topic)}).Error; err != nil { return err }
+		}
+		for _, item := range req.Vocabulary {
+			vocab := models.LessonVocabularyItem{LessonReportID: report.ID, Term: item.Term, Translation: item.Translation, Example: item.Example}
+			if err := tx.Create(&vocab).Error; err != nil { return err }
+		}
+		for _, rating := range req.SkillRatings {
+			if err := tx.Create(&models.LessonSkillRating{LessonReportID: report.ID, Skill: rating.Skill, Level: rating.Level}).Error; err != nil { return err }
+		}
+		for _, hw := range req.Homework {
+			assignment := models.HomeworkAssignment{
+				LessonReportID: report.ID,
+				BookingID:      booking.ID,
+				TeacherID:      booking.TeacherID,
+				StudentID:      booking.StudentID,
+				Title:          hw.Title,
+				Instructions:   hw.Instructions,
+				DueAt:          hw.DueAt,
+				Status:         "assigned",
+			}
+			if err := tx.Create(&assignment).Error; err != nil { return err }
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save lesson report"})
+	}
+
+	database.DB.Scopes(withReportDetails).First(&report, "id = ?", report.ID)
+
+	emailBody := "<h1>Your Lesson Report is Ready</h1><p>Your teacher has shared notes from your class.</p>"
+	if len(req.Homework) > 0 {
+		emailBody += fmt.Sprintf("<p>You have %d new homework assignment(s). Please log in to view and submit them.</p>", len(req.Homework))
+	}
+	go notifications.SendEmail(booking.Student.FullName, booking.Student.Email, "Your Lesson Report is Ready", emailBody)
+
+	return c.JSON(report)
+}
+
+func GetLessonReport(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	userID, _ := uuid.Parse(claims["user_id"].(string))
+
+	var report models.LessonReport
+	if err := database.DB.Scopes(withReportDetails).First(&report, "booking_id = ?", c.Params("bookingId")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No lesson report has been written for this booking"})
+	}
+	if report.StudentID != userID && report.TeacherID != userID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You do not have access to this lesson report"})
+	}
+
+	return c.JSON(report)
+}
+
+func GetMyHomework(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	studentID, _ := uuid.Parse(claims["user_id"].(string))
+
+	query := database.DB.Preload("Resource").Where("student_id = ?", studentID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var homework []models.HomeworkAssignment
+	query.Order("due_at asc").Find(&homework)
+
+	return c.JSON(homework)
+}
+
+func GetTeacherHomework(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	teacherID, _ := uuid.Parse(claims["user_id"].(string))
+
+	query := database.DB.Preload("Resource").Where("teacher_id = ?", teacherID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var homework []models.HomeworkAssignment
+	query.Order("due_at asc").Find(&homework)
+
+	return c.JSON(homework)
+}
+
+// SubmitHomework accepts a text answer, an uploaded file (stored as a booking resource), or both.
+// Students may resubmit until the assignment has been graded.
+func SubmitHomework(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	studentID, _ := uuid.Parse(claims["user_id"].(string))
+
+	var homework models.HomeworkAssignment
+	if err := database.DB.First(&homework, "id = ?", c.Params("homeworkId")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Homework not found"})
+	}
+	if homework.StudentID != studentID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "This homework was not assigned to you"})
+	}
+	if homework.Status == "graded" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "This homework has already been graded"})
+	}
+
+	text := strings.TrimSpace(c.FormValue("text"))
+	if text == "" {
+		var body struct {
+			Text string `json:"text"`
+		}
+		if c.BodyParser(&body) == nil {
+			text = strings.TrimSpace(body.Text)
+		}
+	}
+	file, _ := c.FormFile("file")
+	if text == "" && file == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Provide a text answer or a file"})
+	}
+
+	if file != nil {
+		resource, err := uploadBookingResource(homework.BookingID, file)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to upload file."})
+		}
+		homework.ResourceID = &resource.ID
+	}
+	if text != "" {
+		homework.SubmissionText = &text
+	}
+
+	now := time.Now()
+	homework.Status = "submitted"
+	homework.SubmittedAt = &now
+	homework.SubmittedLate = now.After(homework.DueAt)
+	if err := database.DB.Save(&homework).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to submit homework"})
+	}
+
+	var teacher models.User
+	if err := database.DB.First(&teacher, "id = ?", homework.TeacherID).Error; err == nil {
+		go notifications.SendEmail(teacher.FullName, teacher.Email, "Homework Submitted", fmt.Sprintf("<p>Your student has submitted the homework \"%s\". Please log in to grade it.</p>", homework.Title))
+	}
+
+	database.DB.Preload("Resource").First(&homework, "id = ?", homework.ID)
+	return c.JSON(homework)
+}
+
+type GradeHomeworkRequest struct {
+	Score   *int   `json:"score" validate:"required,min=0,max=100"`
+	Comment string `json:"comment"`
+}
+
+func GradeHomework(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	teacherID, _ := uuid.Parse(claims["user_id"].(string))
+
+	var req GradeHomeworkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var homework models.HomeworkAssignment
+	if err := database.DB.First(&homework, "id = ?", c.Params("homeworkId")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Homework not found"})
+	}
+	if homework.TeacherID != teacherID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You did not assign this homework"})
+	}
+	if homework.Status != "submitted" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Only submitted homework can be graded"})
+	}
+
+	now := time.Now()
+	homework.Status = "graded"
+	homework.Score = req.Score
+	homework.GradedAt = &now
+	if req.Comment != "" {
+		homework.TeacherComment = &req.Comment
+	}
+	if err := database.DB.Save(&homework).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to grade homework"})
+	}
+
+	var student models.User
+	if err := database.DB.First(&student, "id = ?", homework.StudentID).Error; err == nil {
+		go notifications.SendEmail(student.FullName, student.Email, "Your Homework Has Been Graded", fmt.Sprintf("<p>Your homework \"%s\" was graded: <b>%d/100</b>.</p>", homework.Title, *homework.Score))
+	}
+
+	return c.JSON(homework)
+}
+
+func withReportDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("Topics").Preload("Vocabulary").Preload("SkillRatings").Preload("Homework.Resource")
+}
+
+type SkillLevel struct {
+	Skill   string    `json:"skill"`
+	Level   string    `json:"level"`
+	RatedAt time.Time `json:"rated_at"`
+}
+
+// studentLessonHistory returns a student's lesson reports, homework and most recent CEFR level per skill,
+// limited to one teacher's classes when teacherID is set.
+func studentLessonHistory(studentID uuid.UUID, teacherID *uuid.UUID) fiber.Map {
+	reports := database.DB.Scopes(withReportDetails).Where("student_id = ?", studentID)
+	homework := database.DB.Preload("Resource").Where("student_id = ?", studentID)
+	levels := database.DB.Table("lesson_skill_ratings").
+		Select("DISTINCT ON (lesson_skill_ratings.skill) lesson_skill_ratings.skill, lesson_skill_ratings.level, lesson_reports.created_at AS rated_at").
+		Joins("JOIN lesson_reports ON lesson_reports.id = lesson_skill_ratings.lesson_report_id").
+		Where("lesson_reports.student_id = ?", studentID)
+	if teacherID != nil {
+		reports = reports.Where("teacher_id = ?", *teacherID)
+		homework = homework.Where("teacher_id = ?", *teacherID)
+		levels = levels.Where("lesson_reports.teacher_id = ?", *teacherID)
+	}
+
+	var lessonReports []models.LessonReport
+	reports.Order("created_at desc").Find(&lessonReports)
+
+	var assignments []models.HomeworkAssignment
+	homework.Order("due_at desc").Find(&assignments)
+
+	var skillLevels []SkillLevel
+	levels.Order("lesson_skill_ratings.skill, lesson_reports.created_at desc").Scan(&skillLevels)
+
+	return fiber.Map{
+		"lesson_reports": lessonReports,
+		"homework":       assignments,
+		"skill_levels":   skillLevels,
+	}
+}
@@ -3,11 +3,11 @@ package handlers
 import (
 	"errors"
 	"log"
-	"strings"
+	"net/http"
+	"net/url"
 
 	"github.com/anjiri1684/language_tutor/database"
 	"github.com/anjiri1684/language_tutor/models"
-	"github.com/anjiri1684/language_tutor/notifications"
 	"github.com/anjiri1684/language_tutor/payments"
 	"github.com/anjiri1684/language_tutor/services"
 	"github.com/gofiber/fiber/v2"
@@ -15,115 +15,138 @@ import (
 	"gorm.io/gorm"
 )
 
-type KcbWebhookPayload struct {
-	Body struct {
-		StkCallback struct {
-			MerchantRequestID string `json:"MerchantRequestID"`
-			CheckoutRequestID string `json:"CheckoutRequestID"`
-			ResultCode        int    `json:"ResultCode"`
-			ResultDesc        string `json:"ResultDesc"`
-			CallbackMetadata  struct {
-				Item []struct {
-					Name  string      `json:"Name"`
-					Value interface{} `json:"Value"`
-				} `json:"Item"` 
-			} `json:"CallbackMetadata"`
-			Reference string `json:"Reference"`
-		} `json:"stkCallback"`
-	} `json:"Body"`
-}
-
-func HandlePaymentWebhook(c *fiber.Ctx) error {
-	var payload KcbWebhookPayload
-	if err := c.BodyParser(&payload); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse webhook payload"})
+// initiatePayment starts a provider payment for a freshly created pending payment record
+// and stores the references the provider hands back.
+func initiatePayment(gateway payments.Gateway, payment *models.Payment, phoneNumber, description string) (*payments.InitiateResult, error) {
+	result, err := gateway.Initiate(payments.InitiateRequest{
+		PaymentID:   payment.ID.String(),
+		Amount:      payment.Amount,
+		Currency:    payment.Currency,
+		PhoneNumber: phoneNumber,
+		Description: description,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	stk := payload.Body.StkCallback
-
-	var paymentRefID string
-	parts := strings.Split(stk.Reference, "-")
-	if len(parts) == 2 {
-		paymentRefID = parts[1] 
-	} else {
-		paymentRefID = stk.Reference 
+	if result.ProviderOrderID != "" {
+		payment.ProviderOrderID = &result.ProviderOrderID
 	}
+	if result.MerchantRequestID != "" {
+		payment.MerchantRequestID = &result.MerchantRequestID
+	}
+	if phoneNumber != "" {
+		payment.PayerPhone = &phoneNumber
+	}
+	if err := database.DB.Save(payment).Error; err != nil {
+		log.Printf("🔥 Failed to save provider references for payment %s: %v", payment.ID, err)
+	}
+	return result, nil
+}
 
-	log.Printf("Received webhook for MerchantRequestID: %s, PaymentRefID: %s, ResultCode: %d",
-		stk.MerchantRequestID, paymentRefID, stk.ResultCode)
-
-	var payment models.Payment
-	if err := database.DB.Where("id = ?", paymentRefID).First(&payment).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Payment record not found"})
+func initiatePaymentErrorResponse(c *fiber.Ctx, gateway payments.Gateway, err error) error {
+	log.Printf("🔥 CRITICAL: %s payment initiation failed: %v", gateway.Name(), err)
+	if errors.Is(err, payments.ErrInvalidPhoneNumber) || errors.Is(err, payments.ErrPhoneNumberRequired) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Payment could not be initiated, please try again."})
+}
 
-	if payment.Status == "succeeded" {
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Webhook already processed"})
+// paymentResponse adds the payment reference and any provider details the client needs to finish paying.
+func paymentResponse(body fiber.Map, payment models.Payment, result *payments.InitiateResult) fiber.Map {
+	body["payment_id"] = payment.ID
+	if result.ProviderOrderID != "" {
+		body["provider_order_id"] = result.ProviderOrderID
 	}
+	if result.CustomerMessage != "" {
+		body["customer_message"] = result.CustomerMessage
+	}
+	return body
+}
 
-	if stk.ResultCode != 0 {
-		payment.Status = "failed"
-		database.DB.Save(&payment)
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Acknowledged failed payment"})
+// HandlePaymentWebhook receives payment outcome callbacks. The bare /payments/webhook route is the
+// KCB M-Pesa callback URL; other providers post to /payments/webhook/:provider.
+func HandlePaymentWebhook(c *fiber.Ctx) error {
+	gateway, ok := payments.Get(c.Params("provider", "mpesa"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unknown payment provider"})
 	}
 
-	err := database.DB.Transaction(func(tx *gorm.DB) error {
-		var mpesaReceipt string
-		for _, item := range stk.CallbackMetadata.Item {
-			if item.Name == "MpesaReceiptNumber" {
-				if val, ok := item.Value.(string); ok {
-					mpesaReceipt = val
-					break
-				}
-			}
+	headers := http.Header{}
+	for key, values := range c.GetReqHeaders() {
+		for _, value := range values {
+			headers.Add(key, value)
 		}
+	}
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	webhookReq := payments.WebhookRequest{Headers: headers, Query: query, Body: c.Body(), RemoteIP: c.IP()}
 
-		payment.Status = "succeeded"
-		payment.ProviderTxnID = &mpesaReceipt
-		payment.MerchantRequestID = &stk.MerchantRequestID 
-		if err := tx.Save(&payment).Error; err != nil {
-			return err
+	event, err := gateway.VerifyWebhook(webhookReq)
+	if err != nil {
+		services.RecordWebhookRejection(gateway.Name(), webhookReq, err.Error(), nil)
+		if errors.Is(err, payments.ErrWebhookRejected) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Webhook could not be verified"})
 		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse webhook payload"})
+	}
+	if event.Ignored {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Event ignored"})
+	}
 
-		if payment.BookingID != nil {
-			var booking models.Booking
-			if err := tx.Preload("Student").Preload("Teacher").First(&booking, "id = ?", payment.BookingID).Error; err != nil {
-				return err
-			}
-			booking.Status = "confirmed"
-			if err := tx.Save(&booking).Error; err != nil {
-				return err
-			}
-			go func() {
-				notifications.SendEmail(booking.Student.FullName, booking.Student.Email, "Your Booking is Confirmed!", "<h1>Booking Confirmed</h1><p>Your payment was successful and your class is confirmed. You will receive the meeting link shortly.</p>")
-				notifications.SendEmail(booking.Teacher.FullName, booking.Teacher.Email, "You Have a New Booking!", "<h1>New Booking</h1><p>A student has booked a session with you. Please prepare for the class.</p>")
-			}()
-		}
+	log.Printf("Received %s webhook for payment %s (order %s, merchant request %s), succeeded: %t",
+		gateway.Name(), event.PaymentID, event.ProviderOrderID, event.MerchantRequestID, event.Succeeded)
 
-		if payment.StudentBundleID != nil {
-			var studentBundle models.StudentBundle
-			if err := tx.Preload("Student").First(&studentBundle, "id = ?", payment.StudentBundleID).Error; err != nil {
-				return err
-			}
-			studentBundle.Status = "active"
-			if err := tx.Save(&studentBundle).Error; err != nil {
-				return err
+	payment, err := services.FindPaymentForWebhook(gateway.Name(), event)
+	if err != nil {
+		services.RecordWebhookRejection(gateway.Name(), webhookReq, "no matching payment record", nil)
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Payment record not found"})
+	}
+	// References the callback carries must agree with what the provider gave us at initiation.
+	if event.MerchantRequestID != "" && payment.MerchantRequestID != nil && *payment.MerchantRequestID != event.MerchantRequestID {
+		services.RecordWebhookRejection(gateway.Name(), webhookReq, "merchant request ID does not match the payment", &payment.ID)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Webhook could not be verified"})
+	}
+
+	if !event.Succeeded {
+		if payment.Status == "pending" {
+			if err := services.ConfirmPaymentWithProvider(gateway, payment, false); err != nil {
+				services.RecordWebhookRejection(gateway.Name(), webhookReq, err.Error(), &payment.ID)
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Webhook could not be verified"})
 			}
-			go notifications.SendEmail(studentBundle.Student.FullName, studentBundle.Student.Email, "Bundle Purchase Confirmed!", "<h1>Success!</h1><p>Your class bundle purchase is complete. You can now use your class credits to book sessions.</p>")
 		}
+		if err := services.FailPayment(payment.ID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process webhook"})
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Acknowledged failed payment"})
+	}
 
-		return nil
-	})
+	if payment.Status != "succeeded" {
+		if err := services.ConfirmPaymentWithProvider(gateway, payment, true); err != nil {
+			services.RecordWebhookRejection(gateway.Name(), webhookReq, err.Error(), &payment.ID)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Webhook could not be verified"})
+		}
+	}
 
+	paid := services.ProviderPayment{TxnID: event.ProviderTxnID, Amount: event.Amount, Currency: event.Currency}
+	outcome, err := services.CompletePayment(payment.ID, paid, gateway.Name()+" payment succeeded")
 	if err != nil {
-		log.Printf("🔥 CRITICAL: Error processing successful webhook for PaymentRefID %s: %v", paymentRefID, err)
+		log.Printf("🔥 CRITICAL: Error processing successful webhook for payment %s: %v", payment.ID, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process webhook"})
 	}
 
+	switch outcome {
+	case services.PaymentAlreadyFulfilled:
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Webhook already processed"})
+	case services.PaymentLate:
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Acknowledged late payment"})
+	case services.PaymentNeedsReview:
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Acknowledged payment held for review"})
+	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Webhook processed successfully"})
 }
 
-
+// CreatePayPalOrderHandler returns the PayPal order for a pending payment, creating one if the
+// booking or purchase did not already.
 func CreatePayPalOrderHandler(c *fiber.Ctx) error {
 	paymentID := c.Params("paymentId")
 	if _, err := uuid.Parse(paymentID); err != nil {
@@ -138,19 +161,18 @@ func CreatePayPalOrderHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
 	}
 
-	order, err := payments.CreatePayPalOrder(payment.Amount, "USD") 
+	if payment.ProviderOrderID != nil {
+		return c.JSON(fiber.Map{"orderID": *payment.ProviderOrderID})
+	}
+
+	gateway, _ := payments.Get(payment.Provider)
+	result, err := initiatePayment(gateway, &payment, "", "Language Tutor purchase")
 	if err != nil {
 		log.Printf("🔥 PayPal CreateOrder API call failed: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create PayPal order"})
 	}
-	
-	payment.ProviderOrderID = &order.ID
-	if err := database.DB.Save(&payment).Error; err != nil {
-		log.Printf("🔥 Failed to save ProviderOrderID: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update payment record"})
-	}
 
-	return c.JSON(fiber.Map{"orderID": order.ID})
+	return c.JSON(fiber.Map{"orderID": result.ProviderOrderID})
 }
 
 func CapturePayPalOrderHandler(c *fiber.Ctx) error {
@@ -166,45 +188,72 @@ func CapturePayPalOrderHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Payment record not found for this order"})
 	}
 
-	capturedOrder, err := payments.CapturePayPalOrder(req.OrderID)
+	if payment.Status != "pending" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "This payment is no longer pending; the reservation may have expired"})
+	}
+
+	gateway, ok := payments.Get(payment.Provider)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "This payment cannot be captured"})
+	}
+	result, err := gateway.Capture(req.OrderID)
 	if err != nil { return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()}) }
-	
-	if capturedOrder.Status != "COMPLETED" {
+
+	if result.Status != payments.StatusSucceeded {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Order not completed on PayPal's end"})
 	}
 
-	err = database.DB.Transaction(func(tx *gorm.DB) error {
-		payment.Status = "succeeded"
-		payment.ProviderTxnID = &capturedOrder.ID
-		if err := tx.Save(&payment).Error; err != nil { return err }
+	paid := services.ProviderPayment{TxnID: result.ProviderTxnID, Amount: result.Amount, Currency: result.Currency}
+	outcome, err := services.CompletePayment(payment.ID, paid, "PayPal payment captured")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to finalize purchase"})
+	}
+	if outcome == services.PaymentNeedsReview {
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"status": "needs_review", "message": "Payment received but the amount did not match; our team will review it shortly"})
+	}
+
+	return c.JSON(fiber.Map{"status": "success", "message": "Payment captured and purchase confirmed"})
+}
 
-		if payment.BookingID != nil {
-			var booking models.Booking
-			if err := tx.Preload("Student").Preload("Teacher").First(&booking, "id = ?", payment.BookingID).Error; err != nil { return err }
-			booking.Status = "confirmed"
-			if err := tx.Save(&booking).Error; err != nil { return err }
+// HandleRefundWebhook receives the outcome of refunds a provider accepted as pending, such as M-Pesa B2C payouts.
+func HandleRefundWebhook(c *fiber.Ctx) error {
+	gateway, ok := payments.Get(c.Params("provider"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Unknown payment provider"})
+	}
+	verifier, ok := gateway.(payments.RefundWebhookVerifier)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "This provider does not send refund callbacks"})
+	}
 
-			go func() {
-				notifications.SendEmail(booking.Student.FullName, booking.Student.Email, "Your Booking is Confirmed!", "<h1>Booking Confirmed</h1><p>Your PayPal payment was successful and your class is confirmed. You will receive the meeting link shortly.</p>")
-				notifications.SendEmail(booking.Teacher.FullName, booking.Teacher.Email, "You Have a New Booking!", "<h1>New Booking</h1><p>A student has booked and paid for a session with you via PayPal.</p>")
-			}()
-			studentID := booking.StudentID
-			go services.CompleteReferralIfApplicable(studentID)
+	headers := http.Header{}
+	for key, values := range c.GetReqHeaders() {
+		for _, value := range values {
+			headers.Add(key, value)
 		}
-		if payment.StudentBundleID != nil {
-			var studentBundle models.StudentBundle
-			if err := tx.Preload("Student").First(&studentBundle, "id = ?", payment.StudentBundleID).Error; err != nil { return err }
-			studentBundle.Status = "active"
-			if err := tx.Save(&studentBundle).Error; err != nil { return err }
-			
-			go notifications.SendEmail(studentBundle.Student.FullName, studentBundle.Student.Email, "Bundle Purchase Confirmed!", "<h1>Success!</h1><p>Your class bundle purchase is complete.</p>")
-			studentID := studentBundle.StudentID
-			go services.CompleteReferralIfApplicable(studentID)
+	}
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	webhookReq := payments.WebhookRequest{Headers: headers, Query: query, Body: c.Body(), RemoteIP: c.IP()}
+
+	event, err := verifier.VerifyRefundWebhook(webhookReq)
+	if err != nil {
+		services.RecordWebhookRejection(gateway.Name(), webhookReq, err.Error(), nil)
+		if errors.Is(err, payments.ErrWebhookRejected) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Webhook could not be verified"})
 		}
-		return nil
-	})
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse webhook payload"})
+	}
 
-	if err != nil { return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to finalize purchase"}) }
+	refund, err := services.CompleteProviderRefund(gateway.Name(), event)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		services.RecordWebhookRejection(gateway.Name(), webhookReq, "no matching refund record", nil)
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Refund record not found"})
+	}
+	if err != nil {
+		log.Printf("🔥 CRITICAL: Error processing %s refund callback: %v", gateway.Name(), err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process webhook"})
+	}
 
-	return c.JSON(fiber.Map{"status": "success", "message": "Payment captured and purchase confirmed"})
-}
\ No newline at end of file
+	log.Printf("%s refund %s for payment %s is now %s", gateway.Name(), refund.ID, refund.PaymentID, refund.Status)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Webhook processed successfully"})
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"time"
+
 	"github.com/anjiri1684/language_tutor/database"
 	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/services"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
@@ -52,7 +55,16 @@ func UpdateProfile(c *fiber.Ctx) error {
 		user.ProfilePictureURL = req.ProfilePictureURL
 	}
 	if req.TimeZone != nil {
-		user.TimeZone = req.TimeZone
+		if *req.TimeZone == "" {
+			user.TimeZone = nil
+		} else {
+			loc, err := services.ParseTimeZone(*req.TimeZone)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			zone := loc.String()
+			user.TimeZone = &zone
+		}
 	}
 	if req.LearningGoals != nil {
 		user.LearningGoals = req.LearningGoals
@@ -66,6 +78,34 @@ func UpdateProfile(c *fiber.Ctx) error {
 	return c.JSON(user)
 }
 
+// viewerLocation picks the zone to render times in: an explicit ?tz= wins, then the caller's profile, then UTC.
+func viewerLocation(c *fiber.Ctx) *time.Location {
+	if tz := c.Query("tz"); tz != "" {
+		if loc, err := services.ParseTimeZone(tz); err == nil {
+			return loc
+		}
+	}
+	if token, ok := c.Locals("user").(*jwt.Token); ok {
+		claims := token.Claims.(jwt.MapClaims)
+		var user models.User
+		if err := database.DB.Select("id", "time_zone").First(&user, "id = ?", claims["user_id"]).Error; err == nil {
+			return services.UserLocation(user)
+		}
+	}
+	return time.UTC
+}
+
+func localizeSlots(slots []models.AvailabilitySlot, loc *time.Location) {
+	for i := range slots {
+		services.LocalizeSlot(&slots[i], loc)
+	}
+}
+
+func localizeBookings(bookings []models.Booking, loc *time.Location) {
+	for i := range bookings {
+		services.LocalizeBooking(&bookings[i], loc)
+	}
+}
 
 func GetMyProgress(c *fiber.Ctx) error {
 	token := c.Locals("user").(*jwt.Token)
@@ -90,9 +130,14 @@ func GetMyProgress(c *fiber.Ctx) error {
 		Order("start_time desc").
 		Find(&testHistory)
 
+	history := studentLessonHistory(studentID, nil)
+
 	return c.JSON(fiber.Map{
 		"total_classes_completed": totalClasses,
 		"total_hours_learned":     totalHours,
 		"test_history":            testHistory,
+		"lesson_reports":          history["lesson_reports"],
+		"homework":                history["homework"],
+		"skill_levels":            history["skill_levels"],
 	})
 }
\ No newline at end of file
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"mime/multipart"
 	"time"
 
 	config "github.com/anjiri1684/language_tutor/configs"
@@ -31,6 +32,16 @@ func UploadResource(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Resource file is required."})
 	}
 
+	resource, err := uploadBookingResource(booking.ID, file)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to upload file."})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(resource)
+}
+
+// uploadBookingResource stores a file in Cloudinary and records it against the booking.
+func uploadBookingResource(bookingID uuid.UUID, file *multipart.FileHeader) (models.Resource, error) {
 	cld, _ := cloudinary.NewFromURL(config.Config("CLOUDINARY_URL"))
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -40,18 +51,16 @@ func UploadResource(c *fiber.Ctx) error {
 		PublicID: fmt.Sprintf("booking_%s_%s", bookingID, file.Filename),
 	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to upload file."})
+		return models.Resource{}, err
 	}
 
 	resource := models.Resource{
-		BookingID:  booking.ID,
+		BookingID:  bookingID,
 		FileName:   file.Filename,
 		FileURL:    uploadResult.SecureURL,
 		UploadedAt: time.Now(),
 	}
-	database.DB.Create(&resource)
-
-	return c.Status(fiber.StatusCreated).JSON(resource)
+	return resource, database.DB.Create(&resource).Error
 }
 
 func GetBookingResources(c *fiber.Ctx) error {
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 	"time"
 
@@ -9,6 +10,9 @@ import (
 	"github.com/anjiri1684/language_tutor/database"
 	"github.com/anjiri1684/language_tutor/models"
 	"github.com/anjiri1684/language_tutor/notifications"
+	"github.com/anjiri1684/language_tutor/services"
+	"github.com/anjiri1684/language_tutor/services/bookingstate"
+	"github.com/anjiri1684/language_tutor/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
@@ -57,13 +61,34 @@ func ApplyToBeATeacher(c *fiber.Ctx) error {
 }
 
 
+// StartTime and EndTime are RFC3339, or local wall times in TimeZone (defaulting to the teacher's profile zone).
 type CreateAvailabilityRequest struct {
-	StartTime   string `json:"start_time" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
-	EndTime     string `json:"end_time" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	StartTime   string `json:"start_time" validate:"required"`
+	EndTime     string `json:"end_time" validate:"required"`
+	TimeZone    string `json:"time_zone,omitempty" validate:"omitempty,timezone"`
 	LanguageID  string `json:"language_id" validate:"required,uuid"`
 	MaxStudents int    `json:"max_students,omitempty"` 
 }
 
+func parseAvailabilityTimes(req CreateAvailabilityRequest, teacherLoc *time.Location) (time.Time, time.Time, error) {
+	loc := teacherLoc
+	if req.TimeZone != "" {
+		var err error
+		if loc, err = services.ParseTimeZone(req.TimeZone); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	startTime, err := services.ParseScheduleTime(req.StartTime, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	endTime, err := services.ParseScheduleTime(req.EndTime, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return startTime, endTime, nil
+}
+
 func CreateAvailabilitySlot(c *fiber.Ctx) error {
 	token := c.Locals("user").(*jwt.Token)
 	claims := token.Claims.(jwt.MapClaims)
@@ -78,8 +103,11 @@ func CreateAvailabilitySlot(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	startTime, _ := time.Parse(time.RFC3339, req.StartTime)
-	endTime, _ := time.Parse(time.RFC3339, req.EndTime)
+	teacherLoc := viewerLocation(c)
+	startTime, endTime, err := parseAvailabilityTimes(req, teacherLoc)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	if startTime.After(endTime) || startTime.Equal(endTime) {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Start time must be before end time"})
@@ -98,21 +126,124 @@ func CreateAvailabilitySlot(c *fiber.Ctx) error {
 		MaxStudents: maxStudents, 
 	}
 
+	if err := services.CheckSlotConflicts(database.DB, teacherID, startTime, endTime); err != nil {
+		return slotConflictResponse(c, err)
+	}
+
 	if err := database.DB.Create(&newSlot).Error; err != nil {
+		if services.IsSlotOverlapViolation(err) {
+			return slotConflictResponse(c, err)
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create availability slot"})
 	}
 
+	services.LocalizeSlot(&newSlot, teacherLoc)
 	return c.Status(fiber.StatusCreated).JSON(newSlot)
 }
 
+func slotConflictResponse(c *fiber.Ctx, err error) error {
+	var conflictErr *services.SlotConflictError
+	if errors.As(err, &conflictErr) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":             conflictErr.Error(),
+			"conflicting_slots": conflictErr.Conflicts,
+		})
+	}
+	if services.IsSlotOverlapViolation(err) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This time overlaps with another of your availability slots"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check for conflicting slots"})
+}
+
+
+type BulkAvailabilityRequest struct {
+	Slots []CreateAvailabilityRequest `json:"slots" validate:"required,min=1,max=200,dive"`
+}
+
+func CreateAvailabilitySlotsBulk(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	teacherID, _ := uuid.Parse(claims["user_id"].(string))
+
+	var req BulkAvailabilityRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	teacherLoc := viewerLocation(c)
+	newSlots := make([]models.AvailabilitySlot, 0, len(req.Slots))
+	for i, item := range req.Slots {
+		startTime, endTime, err := parseAvailabilityTimes(item, teacherLoc)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Slot %d: %s", i, err.Error())})
+		}
+		if !startTime.Before(endTime) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Slot %d: start time must be before end time", i)})
+		}
+		for j, other := range newSlots {
+			if startTime.Before(other.EndTime) && endTime.After(other.StartTime) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fmt.Sprintf("Slot %d overlaps with slot %d in the same request", i, j)})
+			}
+		}
+
+		maxStudents := 1
+		if item.MaxStudents > 1 {
+			maxStudents = item.MaxStudents
+		}
+		languageID := uuid.MustParse(item.LanguageID)
+		newSlots = append(newSlots, models.AvailabilitySlot{
+			TeacherID:   teacherID,
+			LanguageID:  &languageID,
+			StartTime:   startTime,
+			EndTime:     endTime,
+			MaxStudents: maxStudents,
+		})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, slot := range newSlots {
+			if err := services.CheckSlotConflicts(tx, teacherID, slot.StartTime, slot.EndTime); err != nil { return err }
+		}
+		return tx.Create(&newSlots).Error
+	})
+	var conflictErr *services.SlotConflictError
+	if errors.As(err, &conflictErr) || services.IsSlotOverlapViolation(err) {
+		return slotConflictResponse(c, err)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create availability slots"})
+	}
+
+	localizeSlots(newSlots, teacherLoc)
+	return c.Status(fiber.StatusCreated).JSON(newSlots)
+}
+
 func GetMyAvailability(c *fiber.Ctx) error {
 	token := c.Locals("user").(*jwt.Token)
 	claims := token.Claims.(jwt.MapClaims)
 	teacherIDStr := claims["user_id"].(string)
 
+	loc := viewerLocation(c)
+	from, to, err := services.ParseLocalDateRange(c.Query("from"), c.Query("to"), loc)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	query := database.DB.Where("teacher_id = ?", teacherIDStr)
+	if from != nil {
+		query = query.Where("start_time >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("start_time < ?", *to)
+	}
+
 	var slots []models.AvailabilitySlot
-	database.DB.Where("teacher_id = ?", teacherIDStr).Find(&slots)
+	query.Order("start_time asc").Find(&slots)
 
+	localizeSlots(slots, loc)
 	return c.JSON(slots)
 }
 
@@ -121,11 +252,24 @@ func GetMyAvailability(c *fiber.Ctx) error {
 func GetTeacherAvailability(c *fiber.Ctx) error {
 	teacherID := c.Params("teacherId")
 
+	loc := viewerLocation(c)
+	from, to, err := services.ParseLocalDateRange(c.Query("from"), c.Query("to"), loc)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	query := database.DB.Where("teacher_id = ? AND status = ? AND start_time > ?", teacherID, "available", time.Now())
+	if from != nil {
+		query = query.Where("start_time >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("start_time < ?", *to)
+	}
+
 	var availableSlots []models.AvailabilitySlot
-	database.DB.Where("teacher_id = ? AND status = ? AND start_time > ?", teacherID, "available", time.Now()).
-		Order("start_time asc").
-		Find(&availableSlots)
+	query.Order("start_time asc").Find(&availableSlots)
 
+	localizeSlots(availableSlots, loc)
 	return c.JSON(availableSlots)
 }
 
@@ -185,6 +329,63 @@ func RemoveLanguageFromProfile(c *fiber.Ctx) error {
 }
 
 
+type TeacherRateRequest struct {
+	PricePerSession float64 `json:"price_per_session" validate:"required,gt=0"`
+	Currency        string  `json:"currency,omitempty" validate:"omitempty,iso4217"`
+}
+
+func SetMyLanguageRate(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	teacherID, _ := uuid.Parse(claims["user_id"].(string))
+	langID := c.Params("languageId")
+
+	var req TeacherRateRequest
+	if err := c.BodyParser(&req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"}) }
+	if err := validate.Struct(req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()}) }
+
+	var teacherLanguage models.TeacherLanguage
+	if err := database.DB.Preload("Language").First(&teacherLanguage, "teacher_user_id = ? AND language_id = ?", teacherID, langID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Add this language to your profile before setting a rate"})
+	}
+
+	if req.Currency == "" {
+		req.Currency = teacherLanguage.Language.Currency
+	}
+	if err := services.CheckTeacherPriceBounds(req.PricePerSession, req.Currency, teacherLanguage.Language); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	teacherLanguage.PricePerSession = &req.PricePerSession
+	teacherLanguage.Currency = &req.Currency
+	err := database.DB.Model(&models.TeacherLanguage{}).
+		Where("teacher_user_id = ? AND language_id = ?", teacherID, langID).
+		Updates(map[string]interface{}{"price_per_session": req.PricePerSession, "currency": req.Currency}).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save rate"})
+	}
+
+	return c.JSON(teacherLanguage)
+}
+
+func ClearMyLanguageRate(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	teacherID, _ := uuid.Parse(claims["user_id"].(string))
+	langID := c.Params("languageId")
+
+	result := database.DB.Model(&models.TeacherLanguage{}).
+		Where("teacher_user_id = ? AND language_id = ?", teacherID, langID).
+		Updates(map[string]interface{}{"price_per_session": nil, "currency": nil})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to clear rate"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Language not found on your profile"})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
 
 func ListRescheduleRequests(c *fiber.Ctx) error {
 	token := c.Locals("user").(*jwt.Token)
@@ -192,8 +393,9 @@ func ListRescheduleRequests(c *fiber.Ctx) error {
 	teacherID, _ := uuid.Parse(claims["user_id"].(string))
 
 	var requests []models.Booking
-	database.DB.Preload("Student").Where("teacher_id = ? AND status = ?", teacherID, "reschedule_requested").Find(&requests)
+	database.DB.Preload("Student").Preload("AvailabilitySlot").Preload("ProposedSlot").Where("teacher_id = ? AND status = ?", teacherID, "reschedule_requested").Find(&requests)
 	
+	localizeBookings(requests, viewerLocation(c))
 	return c.JSON(requests)
 }
 
@@ -204,49 +406,66 @@ func ProcessReschedule(c *fiber.Ctx) error {
 	bookingID := c.Params("bookingId")
 
 	type ProcessRequest struct {
-		Decision string `json:"decision" validate:"required,oneof=approve reject"`
+		Decision      string `json:"decision" validate:"required,oneof=approve reject counter"`
+		CounterSlotID string `json:"counter_slot_id,omitempty" validate:"required_if=Decision counter,omitempty,uuid"`
 	}
 	var req ProcessRequest
 	if err := c.BodyParser(&req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"}) }
 	if err := validate.Struct(req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()}) }
 
 	var booking models.Booking
-	if err := database.DB.Preload("Student").First(&booking, "id = ?", bookingID).Error; err != nil {
+	if err := database.DB.Preload("Student").Preload("Teacher").First(&booking, "id = ?", bookingID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Booking not found"})
 	}
 	if booking.TeacherID != teacherID {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "This is not your booking to manage"})
 	}
+	if booking.ProposedSlotID == nil || booking.ProposedBy == nil || *booking.ProposedBy != "student" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "There is no pending reschedule request from the student"})
+	}
 
-	if req.Decision == "approve" {
+	actor := bookingstate.UserActor(teacherID, "teacher")
+	switch req.Decision {
+	case "approve":
 		err := database.DB.Transaction(func(tx *gorm.DB) error {
-			var slot models.AvailabilitySlot
-			if err := tx.First(&slot, "id = ?", booking.AvailabilitySlotID).Error; err != nil { return err }
-
-			slot.StartTime = *booking.ProposedStartTime
-			slot.EndTime = *booking.ProposedEndTime
-			if err := tx.Save(&slot).Error; err != nil { return err }
-
-			booking.Status = "confirmed"
-			booking.ProposedStartTime = nil
-			booking.ProposedEndTime = nil
-			if err := tx.Save(&booking).Error; err != nil { return err }
-			
-			return nil
+			if err := services.MoveBookingToSlot(tx, &booking, *booking.ProposedSlotID); err != nil { return err }
+			if err := bookingstate.Transition(tx, &booking, bookingstate.Confirmed, actor, "Reschedule approved"); err != nil { return err }
+			return setRescheduleProposal(tx, &booking, nil, nil)
 		})
-		if err != nil { return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process reschedule"}) }
-		
+		if err != nil { return rescheduleErrorResponse(c, err, "Failed to process reschedule") }
+
+		go services.RegenerateMeetingLink(booking.ID)
 		go notifications.SendEmail(booking.Student.FullName, booking.Student.Email, "Reschedule Approved", "Your request to reschedule the class has been approved by the teacher.")
 
-	} else { 
-		booking.Status = "confirmed"
-		booking.ProposedStartTime = nil
-		booking.ProposedEndTime = nil
-		database.DB.Save(&booking)
-		
+	case "counter":
+		counterSlot, err := findRescheduleSlot(booking, uuid.MustParse(req.CounterSlotID))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		proposedBy := "teacher"
+		if err := setRescheduleProposal(database.DB, &booking, &counterSlot.ID, &proposedBy); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to process reschedule"})
+		}
+
+		go notifications.SendEmail(booking.Student.FullName, booking.Student.Email, "Your Teacher Proposed Another Time", fmt.Sprintf("Your teacher could not make the time you asked for and proposed %s instead. Please log in to accept or decline.", services.ClassTimeForBoth(counterSlot.StartTime, booking.Student, booking.Teacher)))
+
+	default:
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := bookingstate.Transition(tx, &booking, bookingstate.Confirmed, actor, "Reschedule rejected"); err != nil { return err }
+			return setRescheduleProposal(tx, &booking, nil, nil)
+		})
+		if err != nil { return bookingTransitionResponse(c, err, "Failed to process reschedule") }
+
 		go notifications.SendEmail(booking.Student.FullName, booking.Student.Email, "Reschedule Rejected", "Your request to reschedule the class was not approved by the teacher.")
 	}
 
+	websocket.Publish(websocket.EventRescheduleProcessed, fiber.Map{
+		"booking_id":       booking.ID,
+		"decision":         req.Decision,
+		"status":           booking.Status,
+		"proposed_slot_id": booking.ProposedSlotID,
+	}, booking.StudentID)
+
 	return c.JSON(fiber.Map{"message": "Reschedule request processed successfully"})
 }
 
@@ -254,7 +473,7 @@ func GetTeacherProfile(c *fiber.Ctx) error {
 	teacherID := c.Params("teacherId")
 	
 	var teacher models.Teacher
-	if err := database.DB.Preload("User").Preload("Languages").First(&teacher, "user_id = ? AND status = ?", teacherID, "active").Error; err != nil {
+	if err := database.DB.Preload("User").Preload("Languages").Preload("Rates").First(&teacher, "user_id = ? AND status = ?", teacherID, "active").Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Active teacher not found"})
 	}
 
@@ -264,15 +483,42 @@ func GetTeacherProfile(c *fiber.Ctx) error {
 
 func ListActiveTeachers(c *fiber.Ctx) error {
 	var activeTeachers []models.Teacher
-	query := database.DB.Preload("User").Preload("Languages").Where("status = ?", "active")
 
-	
+	// Each teacher's effective price: their own rate where set, the language default otherwise.
+	// With a language filter it's the price for that language, without one the cheapest they offer.
+	prices := database.DB.Table("teacher_languages tl").
+		Select("tl.teacher_user_id, MIN(COALESCE(tl.price_per_session, l.price_per_session)) AS price").
+		Joins("JOIN languages l ON l.id = tl.language_id").
+		Group("tl.teacher_user_id")
+	priceJoin := "LEFT JOIN (?) tp ON tp.teacher_user_id = teachers.user_id"
 	if langID := c.Query("language_id"); langID != "" {
-		query = query.Joins("JOIN teacher_languages ON teacher_languages.teacher_user_id = teachers.user_id").Where("teacher_languages.language_id = ?", langID)
+		prices = prices.Where("tl.language_id = ?", langID)
+		priceJoin = "JOIN (?) tp ON tp.teacher_user_id = teachers.user_id"
 	}
+
+	query := database.DB.Preload("User").Preload("Languages").Preload("Rates").
+		Select("teachers.*, tp.price AS starting_price").
+		Joins(priceJoin, prices).
+		Where("status = ?", "active")
+
 	if minRating := c.Query("min_rating"); minRating != "" {
 		query = query.Where("avg_rating >= ?", minRating)
 	}
+	if minPrice := c.Query("min_price"); minPrice != "" {
+		query = query.Where("tp.price >= ?", minPrice)
+	}
+	if maxPrice := c.Query("max_price"); maxPrice != "" {
+		query = query.Where("tp.price <= ?", maxPrice)
+	}
+
+	switch c.Query("sort") {
+	case "price_asc":
+		query = query.Order("tp.price asc NULLS LAST")
+	case "price_desc":
+		query = query.Order("tp.price desc NULLS LAST")
+	case "rating":
+		query = query.Order("avg_rating desc")
+	}
 
 	if err := query.Find(&activeTeachers).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve teachers"})
@@ -366,6 +612,37 @@ func UpdateMyTeacherProfile(c *fiber.Ctx) error {
 	return c.JSON(teacher)
 }
 
+func UpdateMyTrialSettings(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	teacherID, _ := uuid.Parse(claims["user_id"].(string))
+
+	type TrialSettingsRequest struct {
+		OffersTrial          bool    `json:"offers_trial"`
+		TrialDurationMinutes int     `json:"trial_duration_minutes" validate:"required_if=OffersTrial true,omitempty,min=10,max=60"`
+		TrialDiscountPercent float64 `json:"trial_discount_percent" validate:"min=0,max=100"`
+	}
+	var req TrialSettingsRequest
+	if err := c.BodyParser(&req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"}) }
+	if err := validate.Struct(req); err != nil { return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()}) }
+
+	var teacher models.Teacher
+	if err := database.DB.First(&teacher, "user_id = ?", teacherID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Teacher profile not found"})
+	}
+
+	teacher.OffersTrial = req.OffersTrial
+	if req.TrialDurationMinutes > 0 {
+		teacher.TrialDurationMinutes = req.TrialDurationMinutes
+	}
+	teacher.TrialDiscountPercent = req.TrialDiscountPercent
+	if err := database.DB.Model(&teacher).Select("offers_trial", "trial_duration_minutes", "trial_discount_percent").Updates(&teacher).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update trial settings"})
+	}
+
+	return c.JSON(teacher)
+}
+
 
 
 func GetMyReviews(c *fiber.Ctx) error {
@@ -405,11 +682,16 @@ func GetStudentProgressForTeacher(c *fiber.Ctx) error {
 	var avgRating struct{ Avg float64 }
 	database.DB.Model(&models.Review{}).Where("teacher_id = ? AND student_id = ?", teacherID, studentID).Select("COALESCE(AVG(rating), 0) as avg").Scan(&avgRating)
 
+	history := studentLessonHistory(student.ID, &teacherID)
+
 	return c.JSON(fiber.Map{
 		"student_name":   student.FullName,
 		"total_classes":  totalClasses,
 		"average_rating": avgRating.Avg,
 		"bookings":       bookings,
+		"lesson_reports": history["lesson_reports"],
+		"homework":       history["homework"],
+		"skill_levels":   history["skill_levels"],
 	})
 }
 
@@ -418,6 +700,7 @@ func GetStudentProgressForTeacher(c *fiber.Ctx) error {
 type MonthlyEarning struct {
 	Month    string  `json:"month"`
 	Earnings float64 `json:"earnings"`
+	Hours    float64 `json:"hours"`
 }
 
 func GetTeacherAnalytics(c *fiber.Ctx) error {
@@ -438,21 +721,56 @@ func GetTeacherAnalytics(c *fiber.Ctx) error {
 
 	var monthlyEarnings []MonthlyEarning
 	database.DB.Model(&models.Booking{}).
-		Select("TO_CHAR(created_at, 'YYYY-MM') as month, SUM(price * ?) as earnings", teacherShare).
+		Select("TO_CHAR(created_at, 'YYYY-MM') as month, SUM(price * ?) as earnings, SUM(duration_minutes) / 60.0 as hours", teacherShare).
 		Where("teacher_id = ? AND status IN ?", teacherID, []string{"completed", "confirmed"}).
 		Group("month").
 		Order("month asc").
 		Scan(&monthlyEarnings)
 		
-	var totalEarnings float64
+	var totalEarnings, totalHours float64
 	for _, me := range monthlyEarnings {
 		totalEarnings += me.Earnings
+		totalHours += me.Hours
+	}
+
+	var hoursTaught float64
+	database.DB.Model(&models.Booking{}).
+		Select("COALESCE(SUM(duration_minutes), 0) / 60.0").
+		Where("teacher_id = ? AND status = 'completed'", teacherID).
+		Scan(&hoursTaught)
+
+	var hourlyEarnings float64
+	if totalHours > 0 {
+		hourlyEarnings = totalEarnings / totalHours
+	}
+
+	// A trial converts when the same student goes on to book a regular class with this teacher.
+	var trialStudents, convertedStudents int64
+	database.DB.Model(&models.Booking{}).
+		Where("teacher_id = ? AND is_trial = ? AND status = ?", teacherID, true, "completed").
+		Distinct("student_id").
+		Count(&trialStudents)
+	database.DB.Model(&models.Booking{}).
+		Where("teacher_id = ? AND is_trial = ? AND status = ?", teacherID, true, "completed").
+		Where("EXISTS (SELECT 1 FROM bookings b2 WHERE b2.student_id = bookings.student_id AND b2.teacher_id = bookings.teacher_id AND b2.is_trial = ? AND b2.status IN ? AND b2.created_at > bookings.created_at)",
+			false, []string{"confirmed", "reschedule_requested", "completed"}).
+		Distinct("student_id").
+		Count(&convertedStudents)
+
+	var trialConversionRate float64
+	if trialStudents > 0 {
+		trialConversionRate = float64(convertedStudents) / float64(trialStudents)
 	}
 
 	return c.JSON(fiber.Map{
 		"total_earnings":        totalEarnings,
 		"average_rating":        teacher.AvgRating,
 		"total_classes_taught":  totalClasses,
+		"total_hours_taught":    hoursTaught,
+		"earnings_per_hour":     hourlyEarnings,
 		"monthly_earnings_data": monthlyEarnings,
+		"trial_lessons":         trialStudents,
+		"trial_conversions":     convertedStudents,
+		"trial_conversion_rate": trialConversionRate,
 	})
 }
\ No newline at end of file
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/services"
+	"github.com/anjiri1684/language_tutor/services/bookingstate"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func JoinWaitlist(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	studentID, _ := uuid.Parse(claims["user_id"].(string))
+
+	slotID, err := uuid.Parse(c.Params("slotId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid slot ID"})
+	}
+
+	var slot models.AvailabilitySlot
+	if err := database.DB.First(&slot, "id = ?", slotID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Availability slot not found"})
+	}
+	if slot.MaxStudents <= 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Waitlists are only available for group classes"})
+	}
+	if !slot.StartTime.After(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "This class has already started"})
+	}
+	if slot.Status != "full" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "This class still has open seats, please book directly"})
+	}
+
+	var existingBookings int64
+	database.DB.Model(&models.Booking{}).
+		Where("student_id = ? AND availability_slot_id = ? AND status <> ?", studentID, slotID, bookingstate.Cancelled).
+		Count(&existingBookings)
+	if existingBookings > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "You are already booked into this class"})
+	}
+
+	var entry models.WaitlistEntry
+	err = database.DB.Where("student_id = ? AND availability_slot_id = ? AND status IN ?", studentID, slotID, []string{"waiting", "offered"}).First(&entry).Error
+	if err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "You are already on the waitlist for this class"})
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to join waitlist"})
+	}
+
+	entry = models.WaitlistEntry{AvailabilitySlotID: slotID, StudentID: studentID, Status: "waiting"}
+	if err := database.DB.Create(&entry).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to join waitlist"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"waitlist_entry": entry,
+		"position":       waitlistPosition(entry),
+	})
+}
+
+func waitlistPosition(entry models.WaitlistEntry) int64 {
+	var ahead int64
+	database.DB.Model(&models.WaitlistEntry{}).
+		Where("availability_slot_id = ? AND status = ? AND created_at < ?", entry.AvailabilitySlotID, "waiting", entry.CreatedAt).
+		Count(&ahead)
+	return ahead + 1
+}
+
+func GetMyWaitlistEntries(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	studentID, _ := uuid.Parse(claims["user_id"].(string))
+
+	var entries []models.WaitlistEntry
+	database.DB.
+		Preload("AvailabilitySlot.Language").
+		Where("student_id = ? AND status IN ?", studentID, []string{"waiting", "offered"}).
+		Order("created_at asc").
+		Find(&entries)
+
+	type WaitlistEntryResponse struct {
+		models.WaitlistEntry
+		Position   int64  `json:"position,omitempty"`
+		ClaimToken string `json:"claim_token,omitempty"`
+	}
+	loc := viewerLocation(c)
+	response := make([]WaitlistEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		services.LocalizeSlot(&entry.AvailabilitySlot, loc)
+		item := WaitlistEntryResponse{WaitlistEntry: entry}
+		if entry.Status == "waiting" {
+			item.Position = waitlistPosition(entry)
+		} else if entry.ClaimToken != nil {
+			item.ClaimToken = *entry.ClaimToken
+		}
+		response = append(response, item)
+	}
+
+	return c.JSON(response)
+}
+
+func LeaveWaitlist(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(jwt.MapClaims)
+	studentID, _ := uuid.Parse(claims["user_id"].(string))
+	entryID := c.Params("entryId")
+
+	var entry models.WaitlistEntry
+	if err := database.DB.First(&entry, "id = ? AND student_id = ?", entryID, studentID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Waitlist entry not found"})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		switch entry.Status {
+		case "waiting":
+			return tx.Model(&entry).Update("status", "left").Error
+		case "offered":
+			// Declining an offer passes the held seat straight on to the next student.
+			if err := services.ExpireWaitlistOffer(tx, entry.ID); err != nil { return err }
+			return tx.Model(&entry).Update("status", "left").Error
+		default:
+			return errors.New("this waitlist entry is no longer active")
+		}
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -6,8 +6,13 @@ import (
 
 	"github.com/anjiri1684/language_tutor/database"
 	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/services"
+	"github.com/anjiri1684/language_tutor/services/bookingstate"
+	"gorm.io/gorm"
 )
 
+// CheckForUnattendedClasses settles confirmed classes that ended 5-15 minutes ago
+// according to which participants joined them.
 func CheckForUnattendedClasses() {
 	log.Println("Running job: CheckForUnattendedClasses...")
 
@@ -15,27 +20,47 @@ func CheckForUnattendedClasses() {
 	upperBound := now.Add(-5 * time.Minute)
 	lowerBound := now.Add(-15 * time.Minute)
 
-	var unattendedBookings []models.Booking
+	var endedBookings []models.Booking
 
 	err := database.DB.
+		Preload("AvailabilitySlot.Language").
+		Preload("Student").
+		Preload("Teacher").
 		Joins("JOIN availability_slots on bookings.availability_slot_id = availability_slots.id").
 		Where("bookings.status = ? AND availability_slots.end_time BETWEEN ? AND ?", "confirmed", lowerBound, upperBound).
-		Find(&unattendedBookings).Error
+		Find(&endedBookings).Error
 
 	if err != nil {
 		log.Printf("Error checking for unattended classes: %v", err)
 		return
 	}
 
-	if len(unattendedBookings) == 0 {
-		log.Println("No unattended classes found.")
+	if len(endedBookings) == 0 {
+		log.Println("No ended classes to settle.")
 		return
 	}
 
-	for _, booking := range unattendedBookings {
-		booking.Status = "unattended"
-		database.DB.Save(&booking)
+	outcomes := make(map[string]int)
+	for _, booking := range endedBookings {
+		var outcome string
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if outcome, err = services.ClassifyAttendance(tx, booking); err != nil {
+				return err
+			}
+			return services.SettleAttendance(tx, &booking, outcome)
+		})
+		if err != nil {
+			log.Printf("Error settling attendance for booking %s: %v", booking.ID, err)
+			continue
+		}
+
+		outcomes[outcome]++
+		if outcome == bookingstate.Completed {
+			go services.AwardRewardsForClassCompletion(booking.StudentID)
+			go services.CheckAndGenerateCertificate(booking)
+		}
 	}
 
-	log.Printf("Marked %d booking(s) as unattended.", len(unattendedBookings))
-}
\ No newline at end of file
+	log.Printf("Settled %d ended class(es): %v", len(endedBookings), outcomes)
+}
@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/services"
+)
+
+func GenerateRecurringAvailability() {
+	log.Println("Running job: GenerateRecurringAvailability...")
+
+	until := time.Now().AddDate(0, 0, 7*services.AvailabilityWeeksAhead())
+
+	var rules []models.AvailabilityRule
+	if err := database.DB.Where("is_active = ?", true).Find(&rules).Error; err != nil {
+		log.Printf("Error loading availability rules: %v", err)
+		return
+	}
+
+	total := 0
+	for _, rule := range rules {
+		created, err := services.MaterializeRule(database.DB, rule, until)
+		if err != nil {
+			log.Printf("Error materializing availability rule %s: %v", rule.ID, err)
+		}
+		total += created
+	}
+
+	log.Printf("Created %d availability slot(s) from %d recurring rule(s).", total, len(rules))
+}
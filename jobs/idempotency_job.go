@@ -0,0 +1,22 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/middleware"
+	"github.com/anjiri1684/language_tutor/models"
+)
+
+func PurgeExpiredIdempotencyKeys() {
+	cutoff := time.Now().Add(-middleware.IdempotencyKeyTTL)
+	result := database.DB.Where("created_at < ?", cutoff).Delete(&models.IdempotencyKey{})
+	if result.Error != nil {
+		log.Printf("Error purging expired idempotency keys: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Purged %d expired idempotency key(s).", result.RowsAffected)
+	}
+}
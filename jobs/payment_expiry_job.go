@@ -0,0 +1,127 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	config "github.com/anjiri1684/language_tutor/configs"
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/notifications"
+	"github.com/anjiri1684/language_tutor/services"
+	"github.com/anjiri1684/language_tutor/services/bookingstate"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultPaymentHoldMinutes = 15
+
+func paymentHoldWindow() time.Duration {
+	minutes, err := strconv.Atoi(config.Config("PAYMENT_HOLD_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = defaultPaymentHoldMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func ExpireUnpaidBookings() {
+	log.Println("Running job: ExpireUnpaidBookings...")
+
+	cutoff := time.Now().Add(-paymentHoldWindow())
+
+	var stalePayments []models.Payment
+	err := database.DB.
+		Where("status IN ? AND created_at < ?", []string{"pending", "failed"}, cutoff).
+		Where("booking_id IN (SELECT id FROM bookings WHERE status = ?) OR student_bundle_id IN (SELECT id FROM student_bundles WHERE status = ?)", "pending_payment", "pending_payment").
+		Find(&stalePayments).Error
+	if err != nil {
+		log.Printf("Error checking for unpaid bookings: %v", err)
+		return
+	}
+
+	if len(stalePayments) == 0 {
+		return
+	}
+
+	expired := 0
+	for _, stale := range stalePayments {
+		var student models.User
+		var expiredBooking bool
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			var payment models.Payment
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, "id = ?", stale.ID).Error; err != nil {
+				return err
+			}
+			switch payment.Status {
+			case "pending":
+				payment.Status = "expired"
+				if err := tx.Save(&payment).Error; err != nil {
+					return err
+				}
+			case "failed":
+			default:
+				return nil
+			}
+
+			if payment.BookingID != nil {
+				var booking models.Booking
+				if err := tx.Preload("Student").First(&booking, "id = ?", payment.BookingID).Error; err != nil {
+					return err
+				}
+				if booking.Status != bookingstate.PendingPayment {
+					return nil
+				}
+				if err := bookingstate.Transition(tx, &booking, bookingstate.Cancelled, bookingstate.System, "Payment hold expired"); err != nil {
+					return err
+				}
+				if err := services.ReleaseSeat(tx, booking.AvailabilitySlotID); err != nil {
+					return err
+				}
+				student = booking.Student
+				expiredBooking = true
+			}
+
+			if payment.StudentBundleID != nil {
+				var studentBundle models.StudentBundle
+				if err := tx.Preload("Student").First(&studentBundle, "id = ?", payment.StudentBundleID).Error; err != nil {
+					return err
+				}
+				if studentBundle.Status != "pending_payment" {
+					return nil
+				}
+				studentBundle.Status = "cancelled"
+				if err := tx.Save(&studentBundle).Error; err != nil {
+					return err
+				}
+				student = studentBundle.Student
+			}
+
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error expiring payment %s: %v", stale.ID, err)
+			continue
+		}
+		if student.ID == uuid.Nil {
+			continue
+		}
+		expired++
+
+		what := "class bundle purchase"
+		if expiredBooking {
+			what = "class booking"
+		}
+		go notifications.SendEmail(
+			student.FullName,
+			student.Email,
+			"Your Reservation Has Expired",
+			fmt.Sprintf("<h1>Reservation Expired</h1><p>We did not receive payment for your %s within %d minutes, so the reservation has been released. You are welcome to book again at any time.</p>", what, int(paymentHoldWindow().Minutes())),
+		)
+	}
+
+	log.Printf("Expired %d unpaid reservation(s).", expired)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/anjiri1684/language_tutor/database"
 	"github.com/anjiri1684/language_tutor/models"
 	"github.com/anjiri1684/language_tutor/notifications"
+	"github.com/anjiri1684/language_tutor/services"
 )
 
 func SendClassReminders() {
@@ -42,7 +43,7 @@ func SendClassReminders() {
 		emailSubject := "Reminder: Your Class Starts in 1 Hour!"
 		emailBody := fmt.Sprintf(
 			"<h1>Class Reminder</h1><p>Hi there,</p><p>This is a friendly reminder that your class is scheduled to start in one hour at %s.</p><p><b>Meeting Link:</b> <a href='%s'>Join Class</a></p>",
-			booking.AvailabilitySlot.StartTime.Format(time.Kitchen),
+			services.ClassTimeForBoth(booking.AvailabilitySlot.StartTime, booking.Student, booking.Teacher),
 			*booking.MeetingLink,
 		)
 		
@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/notifications"
+	"github.com/anjiri1684/language_tutor/services"
+	"github.com/anjiri1684/language_tutor/websocket"
+	"gorm.io/gorm"
+)
+
+func ProcessWaitlistOffers() {
+	log.Println("Running job: ProcessWaitlistOffers...")
+
+	now := time.Now()
+
+	var lapsed []models.WaitlistEntry
+	if err := database.DB.Where("status = ? AND offer_expires_at < ?", "offered", now).Find(&lapsed).Error; err != nil {
+		log.Printf("Error checking for lapsed waitlist offers: %v", err)
+		return
+	}
+	for _, entry := range lapsed {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			return services.ExpireWaitlistOffer(tx, entry.ID)
+		})
+		if err != nil {
+			log.Printf("Error expiring waitlist offer %s: %v", entry.ID, err)
+		}
+	}
+
+	database.DB.Model(&models.WaitlistEntry{}).
+		Where("status = ? AND availability_slot_id IN (SELECT id FROM availability_slots WHERE start_time <= ?)", "waiting", now).
+		Update("status", "expired")
+
+	var offers []models.WaitlistEntry
+	err := database.DB.
+		Preload("Student").
+		Preload("AvailabilitySlot.Language").
+		Where("status = ? AND notified_at IS NULL", "offered").
+		Find(&offers).Error
+	if err != nil {
+		log.Printf("Error fetching new waitlist offers: %v", err)
+		return
+	}
+
+	for _, entry := range offers {
+		link := services.WaitlistClaimLink(*entry.ClaimToken)
+		startTime := services.FormatForUser(entry.AvailabilitySlot.StartTime, entry.Student)
+
+		go notifications.SendEmail(
+			entry.Student.FullName,
+			entry.Student.Email,
+			"A Seat Has Opened Up!",
+			fmt.Sprintf("<h1>Good News!</h1><p>A seat is now available in the %s class on %s that you were waiting for. It is held for you until %s.</p><p><a href='%s'>Claim Your Seat</a></p>", entry.AvailabilitySlot.Language.Name, startTime, services.FormatForUser(*entry.OfferExpiresAt, entry.Student), link),
+		)
+		websocket.Publish(websocket.EventWaitlistOffer, map[string]interface{}{
+			"waitlist_entry_id":    entry.ID,
+			"availability_slot_id": entry.AvailabilitySlotID,
+			"claim_token":          *entry.ClaimToken,
+			"claim_url":            link,
+			"offer_expires_at":     entry.OfferExpiresAt,
+		}, entry.StudentID)
+
+		database.DB.Model(&models.WaitlistEntry{}).Where("id = ?", entry.ID).Update("notified_at", now)
+	}
+
+	if len(lapsed) > 0 || len(offers) > 0 {
+		log.Printf("Expired %d and sent %d waitlist offer(s).", len(lapsed), len(offers))
+	}
+}
@@ -0,0 +1,93 @@
+package meetings
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	config "github.com/anjiri1684/language_tutor/configs"
+)
+
+// GoogleMeet creates a calendar event with an attached Meet conference on the
+// platform's Google calendar, authorised with a stored OAuth refresh token.
+type GoogleMeet struct{}
+
+func (GoogleMeet) Name() string { return "google_meet" }
+
+func getGoogleAccessToken() (string, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {config.Config("GOOGLE_CLIENT_ID")},
+		"client_secret": {config.Config("GOOGLE_CLIENT_SECRET")},
+		"refresh_token": {config.Config("GOOGLE_REFRESH_TOKEN")},
+	}
+	resp, err := httpClient.Post("https://oauth2.googleapis.com/token", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil { return "", err }
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get Google access token, status: %s", resp.Status)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", err
+	}
+	return tokenResp.AccessToken, nil
+}
+
+func (GoogleMeet) CreateMeeting(m Meeting) (string, error) {
+	accessToken, err := getGoogleAccessToken()
+	if err != nil { return "", err }
+
+	calendarID := config.Config("GOOGLE_CALENDAR_ID")
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+
+	payload := map[string]interface{}{
+		"summary":     m.Topic,
+		"description": m.Reference,
+		"start":       map[string]string{"dateTime": m.StartTime.UTC().Format(time.RFC3339)},
+		"end":         map[string]string{"dateTime": m.EndTime.UTC().Format(time.RFC3339)},
+		"conferenceData": map[string]interface{}{
+			"createRequest": map[string]interface{}{
+				"requestId":             randomToken(8),
+				"conferenceSolutionKey": map[string]string{"type": "hangoutsMeet"},
+			},
+		},
+	}
+	body, _ := json.Marshal(payload)
+
+	eventsURL := fmt.Sprintf("https://www.googleapis.com/calendar/v3/calendars/%s/events?conferenceDataVersion=1", url.PathEscape(calendarID))
+	req, _ := http.NewRequest("POST", eventsURL, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+
+	resp, err := httpClient.Do(req)
+	if err != nil { return "", err }
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to create Google Meet event: %s", string(respBody))
+	}
+
+	var event struct {
+		HangoutLink string `json:"hangoutLink"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&event); err != nil {
+		return "", err
+	}
+	if event.HangoutLink == "" {
+		return "", fmt.Errorf("google calendar event was created without a Meet link")
+	}
+	return event.HangoutLink, nil
+}
@@ -0,0 +1,21 @@
+package meetings
+
+import (
+	"fmt"
+	"strings"
+
+	config "github.com/anjiri1684/language_tutor/configs"
+)
+
+// Jitsi generates unguessable room names on a Jitsi Meet server; rooms are created on first join.
+type Jitsi struct{}
+
+func (Jitsi) Name() string { return "jitsi" }
+
+func (Jitsi) CreateMeeting(m Meeting) (string, error) {
+	baseURL := strings.TrimRight(config.Config("JITSI_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "https://meet.jit.si"
+	}
+	return fmt.Sprintf("%s/ClassLearning-%s", baseURL, randomToken(12)), nil
+}
@@ -0,0 +1,68 @@
+package meetings
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	config "github.com/anjiri1684/language_tutor/configs"
+)
+
+// httpClient is shared by the providers that call out to an API, so a hung provider cannot hold up a confirmation.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Meeting describes the class a room is being created for.
+type Meeting struct {
+	Reference string
+	Topic     string
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// Provider creates a video room for a class and returns its join link.
+type Provider interface {
+	Name() string
+	CreateMeeting(m Meeting) (string, error)
+}
+
+var (
+	registryMutex sync.RWMutex
+	providers     = map[string]Provider{
+		"jitsi":       Jitsi{},
+		"zoom":        Zoom{},
+		"google_meet": GoogleMeet{},
+	}
+)
+
+// Register adds or replaces a provider that can then be selected with MEETING_PROVIDER.
+func Register(p Provider) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	providers[p.Name()] = p
+}
+
+// Current returns the provider named by MEETING_PROVIDER, falling back to Jitsi.
+func Current() Provider {
+	name := strings.ToLower(strings.TrimSpace(config.Config("MEETING_PROVIDER")))
+
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	if p, ok := providers[name]; ok {
+		return p
+	}
+	return providers["jitsi"]
+}
+
+// Default returns the built-in Jitsi provider, which needs no credentials.
+func Default() Provider {
+	return Jitsi{}
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,80 @@
+package meetings
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	config "github.com/anjiri1684/language_tutor/configs"
+)
+
+// Zoom schedules meetings through a Server-to-Server OAuth app.
+type Zoom struct{}
+
+func (Zoom) Name() string { return "zoom" }
+
+func getZoomAccessToken() (string, error) {
+	tokenURL := "https://zoom.us/oauth/token?grant_type=account_credentials&account_id=" + url.QueryEscape(config.Config("ZOOM_ACCOUNT_ID"))
+	req, err := http.NewRequest("POST", tokenURL, nil)
+	if err != nil { return "", err }
+	req.SetBasicAuth(config.Config("ZOOM_CLIENT_ID"), config.Config("ZOOM_CLIENT_SECRET"))
+
+	resp, err := httpClient.Do(req)
+	if err != nil { return "", err }
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get Zoom access token, status: %s", resp.Status)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", err
+	}
+	return tokenResp.AccessToken, nil
+}
+
+func (Zoom) CreateMeeting(m Meeting) (string, error) {
+	accessToken, err := getZoomAccessToken()
+	if err != nil { return "", err }
+
+	payload := map[string]interface{}{
+		"topic":      m.Topic,
+		"type":       2,
+		"start_time": m.StartTime.UTC().Format("2006-01-02T15:04:05Z"),
+		"duration":   int(m.EndTime.Sub(m.StartTime).Minutes()),
+		"timezone":   "UTC",
+		"agenda":     m.Reference,
+		"settings": map[string]interface{}{
+			"join_before_host": true,
+			"waiting_room":     false,
+		},
+	}
+	body, _ := json.Marshal(payload)
+
+	req, _ := http.NewRequest("POST", "https://api.zoom.us/v2/users/me/meetings", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+
+	resp, err := httpClient.Do(req)
+	if err != nil { return "", err }
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("failed to create Zoom meeting: %s", string(respBody))
+	}
+
+	var meeting struct {
+		JoinURL string `json:"join_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&meeting); err != nil {
+		return "", err
+	}
+	return meeting.JoinURL, nil
+}
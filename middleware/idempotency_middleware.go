@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// IdempotencyKeyTTL is how long a key's response is replayed before the key can be reused.
+	IdempotencyKeyTTL = 24 * time.Hour
+	// A key left processing this long belongs to a request that died without finishing.
+	idempotencyLockTimeout = 2 * time.Minute
+)
+
+func idempotencyRequestHash(c *fiber.Ctx) string {
+	sum := sha256.New()
+	sum.Write([]byte(c.Method() + " " + c.OriginalURL() + "\n"))
+	sum.Write(c.Body())
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// claimIdempotencyKey inserts the key as processing, or returns the existing record if another
+// request already holds it. Expired and abandoned records are cleared and claimed afresh.
+func claimIdempotencyKey(record *models.IdempotencyKey) (bool, *models.IdempotencyKey, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return false, nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return true, nil, nil
+		}
+
+		var existing models.IdempotencyKey
+		if err := database.DB.Where("user_id = ? AND key = ?", record.UserID, record.Key).First(&existing).Error; err != nil {
+			return false, nil, err
+		}
+		expired := existing.CreatedAt.Before(time.Now().Add(-IdempotencyKeyTTL))
+		abandoned := existing.Status == "processing" && existing.UpdatedAt.Before(time.Now().Add(-idempotencyLockTimeout))
+		if !expired && !abandoned {
+			return false, &existing, nil
+		}
+		database.DB.Where("id = ? AND updated_at = ?", existing.ID, existing.UpdatedAt).Delete(&models.IdempotencyKey{})
+		record.ID = uuid.Nil
+	}
+	return false, nil, fiber.NewError(fiber.StatusConflict, "Idempotency-Key is busy, please retry")
+}
+
+// Idempotency makes a route safe to retry when the client sends an Idempotency-Key header: the first
+// request runs and its response is stored, and repeats with the same key and body get that response
+// back. Requests without the header run normally. It must come after Protected.
+func Idempotency() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("Idempotency-Key")
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > 255 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Idempotency-Key must be at most 255 characters"})
+		}
+
+		var userID uuid.UUID
+		if token, ok := c.Locals("user").(*jwt.Token); ok {
+			claims := token.Claims.(jwt.MapClaims)
+			userID, _ = uuid.Parse(claims["user_id"].(string))
+		}
+
+		record := models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Method:      c.Method(),
+			Path:        c.Path(),
+			RequestHash: idempotencyRequestHash(c),
+			Status:      "processing",
+		}
+		claimed, existing, err := claimIdempotencyKey(&record)
+		if err != nil {
+			return err
+		}
+
+		if !claimed {
+			if existing.RequestHash != record.RequestHash {
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": "This Idempotency-Key was already used for a different request"})
+			}
+			if existing.Status != "completed" {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "A request with this Idempotency-Key is still being processed"})
+			}
+			c.Set("Idempotent-Replayed", "true")
+			if existing.ContentType != "" {
+				c.Set(fiber.HeaderContentType, existing.ContentType)
+			}
+			return c.Status(existing.StatusCode).SendString(existing.ResponseBody)
+		}
+
+		// Server errors and panics release the key so the client's retry actually runs again.
+		completed := false
+		defer func() {
+			if !completed {
+				database.DB.Delete(&models.IdempotencyKey{}, "id = ?", record.ID)
+			}
+		}()
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			return nil
+		}
+
+		err = database.DB.Model(&models.IdempotencyKey{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+			"status":        "completed",
+			"status_code":   status,
+			"content_type":  string(c.Response().Header.ContentType()),
+			"response_body": string(c.Response().Body()),
+		}).Error
+		completed = err == nil
+		return nil
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+	"github.com/google/uuid"
+)
+
+type AttendanceEvent struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BookingID  uuid.UUID `gorm:"not null;index" json:"booking_id"`
+	UserID     uuid.UUID `gorm:"not null" json:"user_id"`
+	Role       string    `gorm:"size:20;not null" json:"role"`
+	Event      string    `gorm:"size:10;not null" json:"event"`
+	Source     string    `gorm:"size:50;not null" json:"source"`
+	OccurredAt time.Time `gorm:"not null" json:"occurred_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
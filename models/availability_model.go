@@ -16,6 +16,14 @@ type AvailabilitySlot struct {
 	MaxStudents     int `gorm:"not null;default:1" json:"max_students"`
 	CurrentStudents int `gorm:"not null;default:0" json:"current_students"`
 
+	AvailabilityRuleID *uuid.UUID `gorm:"index" json:"availability_rule_id,omitempty"`
+	// One room per slot, shared by every student booked into a group class.
+	MeetingLink *string `gorm:"type:text" json:"-"`
+
+	LocalStartTime *time.Time `gorm:"-" json:"local_start_time,omitempty"`
+	LocalEndTime   *time.Time `gorm:"-" json:"local_end_time,omitempty"`
+	TimeZone       string     `gorm:"-" json:"time_zone,omitempty"`
+
 	Teacher   User      `gorm:"foreignkey:TeacherID" json:"teacher,omitempty"`
 	Language  Language  `gorm:"foreignkey:LanguageID" json:"language,omitempty"`
 }
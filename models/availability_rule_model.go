@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+	"github.com/google/uuid"
+)
+
+type AvailabilityRule struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TeacherID      uuid.UUID  `gorm:"not null" json:"-"`
+	LanguageID     uuid.UUID  `gorm:"not null" json:"language_id"`
+	DaysOfWeek     string     `gorm:"size:20;not null" json:"days_of_week"`
+	StartLocalTime string     `gorm:"size:5;not null" json:"start_local_time"`
+	EndLocalTime   string     `gorm:"size:5;not null" json:"end_local_time"`
+	TimeZone       string     `gorm:"size:100;not null" json:"time_zone"`
+	EffectiveFrom  time.Time  `gorm:"not null" json:"effective_from"`
+	EffectiveUntil *time.Time `json:"effective_until"`
+	Exceptions     string     `gorm:"type:text" json:"exceptions"`
+	MaxStudents    int        `gorm:"not null;default:1" json:"max_students"`
+	IsActive       bool       `gorm:"default:true" json:"is_active"`
+
+	Language Language `gorm:"foreignkey:LanguageID" json:"language,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
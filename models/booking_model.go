@@ -7,23 +7,27 @@ import (
 
 type Booking struct {
 	ID               uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	StudentID        uuid.UUID `gorm:"not null"`
-	TeacherID        uuid.UUID `gorm:"not null"`
+	StudentID        uuid.UUID `gorm:"not null;uniqueIndex:idx_bookings_one_trial,where:is_trial = true AND status <> 'cancelled'"`
+	TeacherID        uuid.UUID `gorm:"not null;uniqueIndex:idx_bookings_one_trial"`
 	AvailabilitySlotID uuid.UUID `gorm:"not null"`
 	Status           string    `gorm:"size:20;not null;default:'pending_payment'"`
 	Price            float64   `gorm:"type:numeric(10,2);not null"`
+	DurationMinutes  int       `gorm:"not null;default:0"`
 	Currency 			string    `gorm:"size:3"`
 	MeetingLink      *string   `gorm:"size:255"`
+	StudentBundleID  *uuid.UUID
+	IsTrial          bool      `gorm:"default:false"`
 
 	TeacherFeedback  *string   `gorm:"type:text"`
 	StudentFeedback  *string   `gorm:"type:text"`
 	
-	ProposedStartTime *time.Time
-	ProposedEndTime   *time.Time
+	ProposedSlotID   *uuid.UUID
+	ProposedBy       *string   `gorm:"size:20"`
 
 	Student          User             `gorm:"foreignkey:StudentID"`
 	Teacher          User             `gorm:"foreignkey:TeacherID"`
 	AvailabilitySlot AvailabilitySlot `gorm:"foreignkey:AvailabilitySlotID"`
+	ProposedSlot     *AvailabilitySlot `gorm:"foreignkey:ProposedSlotID"`
 	
 	CreatedAt        time.Time
 	UpdatedAt        time.Time
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+	"github.com/google/uuid"
+)
+
+type BookingStatusHistory struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BookingID uuid.UUID  `gorm:"not null;index" json:"booking_id"`
+	ActorID   *uuid.UUID `json:"actor_id"`
+	ActorRole string     `gorm:"size:20;not null" json:"actor_role"`
+	OldStatus string     `gorm:"size:20" json:"old_status"`
+	NewStatus string     `gorm:"size:20;not null" json:"new_status"`
+	Reason    string     `gorm:"type:text" json:"reason"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (BookingStatusHistory) TableName() string {
+	return "booking_status_history"
+}
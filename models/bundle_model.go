@@ -9,7 +9,7 @@ type Bundle struct {
 	ID              uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 	Name            string    `gorm:"size:255;not null"`
 	LanguageID      uuid.UUID `gorm:"not null"`
-	NumberOfClasses int       `gorm:"not null"`
+	TotalMinutes    int       `gorm:"not null;default:0" json:"total_minutes"`
 	Price           float64   `gorm:"type:numeric(10,2);not null"`
 	Currency string    `gorm:"size:3;default:'USD'"`
 	IsActive        bool      `gorm:"default:true"`
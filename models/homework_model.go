@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+	"github.com/google/uuid"
+)
+
+type HomeworkAssignment struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	LessonReportID uuid.UUID  `gorm:"not null;index" json:"lesson_report_id"`
+	BookingID      uuid.UUID  `gorm:"not null;index" json:"booking_id"`
+	TeacherID      uuid.UUID  `gorm:"not null;index" json:"teacher_id"`
+	StudentID      uuid.UUID  `gorm:"not null;index" json:"student_id"`
+	Title          string     `gorm:"size:255;not null" json:"title"`
+	Instructions   string     `gorm:"type:text" json:"instructions"`
+	DueAt          time.Time  `gorm:"not null" json:"due_at"`
+	Status         string     `gorm:"size:20;not null;default:'assigned'" json:"status"` // assigned, submitted, graded
+
+	SubmissionText *string    `gorm:"type:text" json:"submission_text,omitempty"`
+	ResourceID     *uuid.UUID `json:"resource_id,omitempty"`
+	SubmittedAt    *time.Time `json:"submitted_at,omitempty"`
+	SubmittedLate  bool       `gorm:"default:false" json:"submitted_late"`
+
+	Score          *int       `json:"score,omitempty"`
+	TeacherComment *string    `gorm:"type:text" json:"teacher_comment,omitempty"`
+	GradedAt       *time.Time `json:"graded_at,omitempty"`
+
+	Resource *Resource `gorm:"foreignkey:ResourceID" json:"resource,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+	"github.com/google/uuid"
+)
+
+// IdempotencyKey remembers the response to a client-keyed request so a retry replays it
+// instead of running the request again. Keys are scoped to the user who sent them.
+type IdempotencyKey struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID       uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_idempotency_user_key"`
+	Key          string    `gorm:"size:255;not null;uniqueIndex:idx_idempotency_user_key"`
+	Method       string    `gorm:"size:10;not null"`
+	Path         string    `gorm:"size:255;not null"`
+	RequestHash  string    `gorm:"size:64;not null"`
+	Status       string    `gorm:"size:20;not null;default:'processing'"` // processing, completed
+	StatusCode   int
+	ContentType  string    `gorm:"size:100"`
+	ResponseBody string    `gorm:"type:text"`
+
+	CreatedAt time.Time `gorm:"index"`
+	UpdatedAt time.Time
+}
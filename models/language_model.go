@@ -8,4 +8,7 @@ type Language struct {
 	Name            string    `gorm:"size:100;not null;unique" json:"name"` 
 	PricePerSession float64   `gorm:"type:numeric(10,2);not null;default:0.00" json:"PricePerSession"`
 	Currency        string    `gorm:"size:3;not null;default:'USD'"`
+	SessionMinutes  int       `gorm:"not null;default:60" json:"session_minutes"`
+	MinTeacherPrice *float64  `gorm:"type:numeric(10,2)" json:"min_teacher_price"`
+	MaxTeacherPrice *float64  `gorm:"type:numeric(10,2)" json:"max_teacher_price"`
 }
\ No newline at end of file
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+	"github.com/google/uuid"
+)
+
+type LessonReport struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BookingID uuid.UUID `gorm:"not null;uniqueIndex" json:"booking_id"`
+	TeacherID uuid.UUID `gorm:"not null;index" json:"teacher_id"`
+	StudentID uuid.UUID `gorm:"not null;index" json:"student_id"`
+	Summary   string    `gorm:"type:text" json:"summary"`
+
+	Topics       []LessonTopic          `gorm:"foreignkey:LessonReportID;constraint:OnDelete:CASCADE" json:"topics"`
+	Vocabulary   []LessonVocabularyItem `gorm:"foreignkey:LessonReportID;constraint:OnDelete:CASCADE" json:"vocabulary"`
+	SkillRatings []LessonSkillRating    `gorm:"foreignkey:LessonReportID;constraint:OnDelete:CASCADE" json:"skill_ratings"`
+	Homework     []HomeworkAssignment   `gorm:"foreignkey:LessonReportID" json:"homework"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type LessonTopic struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	LessonReportID uuid.UUID `gorm:"not null;index" json:"-"`
+	Topic          string    `gorm:"size:255;not null" json:"topic"`
+}
+
+type LessonVocabularyItem struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	LessonReportID uuid.UUID `gorm:"not null;index" json:"-"`
+	Term           string    `gorm:"size:255;not null" json:"term"`
+	Translation    string    `gorm:"size:255" json:"translation"`
+	Example        string    `gorm:"type:text" json:"example"`
+}
+
+// LessonSkillRating records the CEFR level (A1-C2) the teacher observed for one skill.
+type LessonSkillRating struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	LessonReportID uuid.UUID `gorm:"not null;index" json:"-"`
+	Skill          string    `gorm:"size:20;not null" json:"skill"`
+	Level          string    `gorm:"size:2;not null" json:"level"`
+}
@@ -18,9 +18,17 @@ type Payment struct {
 	Status        string    `gorm:"size:20;not null"`
 	RefundStatus *string `gorm:"size:20"` 
 	RefundReason *string `gorm:"type:text"`
+	RefundAmount *float64 `gorm:"type:numeric(10,2)"`
+	// What the provider reported as actually paid, and why a mismatch was held for review.
+	PaidAmount   *float64 `gorm:"type:numeric(10,2)"`
+	PaidCurrency *string  `gorm:"size:3"`
+	ReviewReason *string  `gorm:"type:text"`
+	// The wallet an M-Pesa payment came from, which refunds are paid back to.
+	PayerPhone   *string  `gorm:"size:20"`
 
 	Booking   Booking   `gorm:"foreignkey:BookingID"`
 	StudentBundle StudentBundle `gorm:"foreignkey:StudentBundleID"` 
+	Refunds       []Refund      `gorm:"foreignkey:PaymentID"`
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
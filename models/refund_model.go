@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+	"github.com/google/uuid"
+)
+
+// Refund is one transfer of money back to a student against a payment. Amount is in the
+// payment's currency; credit refunds also record what was added to the student's balance.
+type Refund struct {
+	ID               uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PaymentID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"payment_id"`
+	Amount           float64    `gorm:"type:numeric(10,2);not null" json:"amount"`
+	Currency         string     `gorm:"size:3" json:"currency"`
+	Destination      string     `gorm:"size:20;not null" json:"destination"` // "provider" or "credit"
+	Provider         string     `gorm:"size:50" json:"provider"`
+	ProviderRefundID *string    `gorm:"size:255;unique" json:"provider_refund_id,omitempty"`
+	CreditAmount     *float64   `gorm:"type:numeric(10,2)" json:"credit_amount,omitempty"`
+	Status           string     `gorm:"size:20;not null;default:'pending'" json:"status"` // pending, processing, succeeded, failed
+	Reason           string     `gorm:"type:text" json:"reason"`
+	FailureReason    *string    `gorm:"type:text" json:"failure_reason,omitempty"`
+	ProcessedByID    *uuid.UUID `gorm:"type:uuid" json:"processed_by_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
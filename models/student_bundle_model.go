@@ -12,7 +12,7 @@ type StudentBundle struct {
 	StudentID        uuid.UUID `gorm:"not null" json:"student_id"`
 	BundleID         uuid.UUID `gorm:"not null" json:"bundle_id"`
 	PurchaseDate     time.Time `gorm:"not null" json:"purchase_date"`
-	RemainingClasses int       `gorm:"not null" json:"remaining_classes"`
+	RemainingMinutes int       `gorm:"not null;default:0" json:"remaining_minutes"`
 	Status           string    `gorm:"size:20;not null;default:'pending_payment'" json:"status"`
 
 	Student User   `gorm:"foreignkey:StudentID" json:"student"`
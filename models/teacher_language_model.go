@@ -3,9 +3,13 @@ package models
 import "github.com/google/uuid"
 
 type TeacherLanguage struct {
-	TeacherUserID uuid.UUID `gorm:"type:uuid;primaryKey"`
-	LanguageID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	TeacherUserID uuid.UUID `gorm:"type:uuid;primaryKey" json:"teacher_user_id"`
+	LanguageID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"language_id"`
 
-	Teacher Teacher  `gorm:"foreignKey:TeacherUserID"`
-	Language Language `gorm:"foreignKey:LanguageID"`
+	// PricePerSession overrides Language.PricePerSession for this teacher when set.
+	PricePerSession *float64 `gorm:"type:numeric(10,2)" json:"price_per_session"`
+	Currency        *string  `gorm:"size:3" json:"currency"`
+
+	Teacher Teacher  `gorm:"foreignKey:TeacherUserID" json:"-"`
+	Language Language `gorm:"foreignKey:LanguageID" json:"language"`
 }
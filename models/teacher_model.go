@@ -12,7 +12,14 @@ type Teacher struct {
 	Status         string      `gorm:"size:20;not null;default:'pending'" json:"status"`
 	AvgRating      float32     `gorm:"default:0" json:"avg_rating"`
 	CurrentBalance float64     `gorm:"type:numeric(10,2);default:0.00" json:"-"` 
+	CancellationCount int      `gorm:"default:0" json:"cancellation_count"`
+	NoShowCount       int      `gorm:"default:0" json:"no_show_count"`
+	OffersTrial          bool    `gorm:"default:false" json:"offers_trial"`
+	TrialDurationMinutes int     `gorm:"default:30" json:"trial_duration_minutes"`
+	TrialDiscountPercent float64 `gorm:"type:numeric(5,2);default:50" json:"trial_discount_percent"`
 	Languages      []*Language `gorm:"many2many:teacher_languages;" json:"languages"`
+	Rates          []TeacherLanguage `gorm:"foreignkey:TeacherUserID;references:UserID" json:"rates,omitempty"`
+	StartingPrice  *float64    `gorm:"->;-:migration" json:"starting_price,omitempty"`
 	User           User        `gorm:"foreignkey:UserID" json:"user"`
 	CreatedAt      time.Time   `json:"-"`
 	UpdatedAt      time.Time   `json:"-"`
@@ -27,6 +27,7 @@ type User struct {
 	
 	ResetPasswordToken        *string    `gorm:"size:255;unique" json:"-"`
 	ResetPasswordTokenExpiresAt *time.Time `json:"-"`
+	CalendarToken     *string `gorm:"size:64;unique" json:"-"`
 	IsActive          bool   `gorm:"default:true"` // <-- Add this line
 	
 	CreatedAt time.Time `json:"created_at"`
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+	"github.com/google/uuid"
+)
+
+type WaitlistEntry struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AvailabilitySlotID uuid.UUID  `gorm:"not null;index" json:"availability_slot_id"`
+	StudentID          uuid.UUID  `gorm:"not null;index" json:"student_id"`
+	Status             string     `gorm:"size:20;not null;default:'waiting'" json:"status"`
+	ClaimToken         *string    `gorm:"size:64;uniqueIndex" json:"-"`
+	OfferedAt          *time.Time `json:"offered_at,omitempty"`
+	OfferExpiresAt     *time.Time `json:"offer_expires_at,omitempty"`
+	NotifiedAt         *time.Time `json:"-"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+
+	AvailabilitySlot AvailabilitySlot `gorm:"foreignkey:AvailabilitySlotID" json:"availability_slot,omitempty"`
+	Student          User             `gorm:"foreignkey:StudentID" json:"-"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+	"github.com/google/uuid"
+)
+
+// WebhookAuditLog records payment callbacks that were refused, for investigating forgery attempts.
+type WebhookAuditLog struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Provider  string     `gorm:"size:50;not null;index" json:"provider"`
+	RemoteIP  string     `gorm:"size:64" json:"remote_ip"`
+	Reason    string     `gorm:"type:text;not null" json:"reason"`
+	Headers   string     `gorm:"type:text" json:"headers"`
+	Body      string     `gorm:"type:text" json:"body"`
+	PaymentID *uuid.UUID `gorm:"index" json:"payment_id,omitempty"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+}
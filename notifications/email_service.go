@@ -2,6 +2,7 @@ package notifications
 
 import (
     "bytes"
+    "encoding/base64"
     "encoding/json"
     "fmt"
     "io"
@@ -21,11 +22,22 @@ type BrevoService struct {
 
 var EmailClient *BrevoService
 
+type Attachment struct {
+    Name    string
+    Content []byte
+}
+
+type brevoAttachment struct {
+    Name    string `json:"name"`
+    Content string `json:"content"`
+}
+
 type brevoPayload struct {
     Sender      map[string]string   `json:"sender"`
     To          []map[string]string `json:"to"`
     Subject     string              `json:"subject"`
     HTMLContent string              `json:"htmlContent"`
+    Attachment  []brevoAttachment   `json:"attachment,omitempty"`
 }
 
 func InitEmailService() {
@@ -49,7 +61,7 @@ func InitEmailService() {
     log.Println("✅ Email service initialized successfully.")
 }
 
-func (s *BrevoService) send(toEmail, toName, subject, htmlContent string) error {
+func (s *BrevoService) send(toEmail, toName, subject, htmlContent string, attachments []Attachment) error {
     url := "https://api.brevo.com/v3/smtp/email"
 
     if toEmail == "" || !strings.Contains(toEmail, "@") {
@@ -67,6 +79,12 @@ func (s *BrevoService) send(toEmail, toName, subject, htmlContent string) error
         Subject:     subject,
         HTMLContent: htmlContent,
     }
+    for _, a := range attachments {
+        payload.Attachment = append(payload.Attachment, brevoAttachment{
+            Name:    a.Name,
+            Content: base64.StdEncoding.EncodeToString(a.Content),
+        })
+    }
 
     body, err := json.Marshal(payload)
     if err != nil {
@@ -104,13 +122,17 @@ func (s *BrevoService) send(toEmail, toName, subject, htmlContent string) error
 }
 
 func SendEmail(toName, toEmail, subject, htmlContent string) {
+    SendEmailWithAttachments(toName, toEmail, subject, htmlContent)
+}
+
+func SendEmailWithAttachments(toName, toEmail, subject, htmlContent string, attachments ...Attachment) {
     if EmailClient == nil {
         log.Println("Email client not initialized, skipping email send.")
         return
     }
 
     log.Printf("Calling SendEmail with toName=%s, toEmail=%s, subject=%s", toName, toEmail, subject)
-    err := EmailClient.send(toEmail, toName, subject, htmlContent)
+    err := EmailClient.send(toEmail, toName, subject, htmlContent, attachments)
     if err != nil {
         log.Printf("🔥 Failed to send email to %s: %v", toEmail, err)
         return
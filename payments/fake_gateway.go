@@ -0,0 +1,146 @@
+package payments
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// FakeGateway is an in-memory Gateway for tests. Orders stay pending until Capture or Complete
+// is called, and refunds are recorded rather than sent anywhere. It accepts any webhook, so it is
+// never registered; tests construct one with NewFakeGateway and call it directly.
+type FakeGateway struct {
+	name     string
+	currency string
+
+	mu      sync.Mutex
+	seq     int
+	orders  map[string]*FakeOrder
+	Refunds []RefundRequest
+	// FailNext makes the next Initiate, Capture or Refund call return this error.
+	FailNext error
+}
+
+type FakeOrder struct {
+	PaymentID string
+	Amount    float64
+	Currency  string
+	Status    string
+	TxnID     string
+}
+
+func NewFakeGateway(name, settlementCurrency string) *FakeGateway {
+	return &FakeGateway{name: name, currency: settlementCurrency, orders: map[string]*FakeOrder{}}
+}
+
+func (g *FakeGateway) Name() string { return g.name }
+
+func (g *FakeGateway) SettlementCurrency() string { return g.currency }
+
+func (g *FakeGateway) takeFailure() error {
+	err := g.FailNext
+	g.FailNext = nil
+	return err
+}
+
+func (g *FakeGateway) Initiate(req InitiateRequest) (*InitiateResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.takeFailure(); err != nil {
+		return nil, err
+	}
+
+	g.seq++
+	orderID := fmt.Sprintf("%s_order_%d", g.name, g.seq)
+	g.orders[orderID] = &FakeOrder{PaymentID: req.PaymentID, Amount: req.Amount, Currency: req.Currency, Status: StatusPending}
+	return &InitiateResult{ProviderOrderID: orderID, CustomerMessage: "Fake payment created"}, nil
+}
+
+// Complete simulates the customer paying (or declining) outside the API, as a webhook would report.
+func (g *FakeGateway) Complete(orderID string, succeeded bool) (*FakeOrder, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	order, ok := g.orders[orderID]
+	if !ok {
+		return nil, fmt.Errorf("unknown fake order %s", orderID)
+	}
+	if succeeded {
+		order.Status = StatusSucceeded
+		order.TxnID = orderID + "_txn"
+	} else {
+		order.Status = StatusFailed
+	}
+	return order, nil
+}
+
+func (g *FakeGateway) Capture(providerOrderID string) (*PaymentResult, error) {
+	g.mu.Lock()
+	failure := g.takeFailure()
+	g.mu.Unlock()
+	if failure != nil {
+		return nil, failure
+	}
+
+	order, err := g.Complete(providerOrderID, true)
+	if err != nil {
+		return nil, err
+	}
+	return &PaymentResult{Status: order.Status, ProviderTxnID: order.TxnID, Amount: order.Amount, Currency: order.Currency}, nil
+}
+
+func (g *FakeGateway) Refund(req RefundRequest) (*RefundResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.takeFailure(); err != nil {
+		return nil, err
+	}
+
+	g.Refunds = append(g.Refunds, req)
+	return &RefundResult{ProviderRefundID: fmt.Sprintf("%s_refund_%d", g.name, len(g.Refunds)), Status: StatusSucceeded}, nil
+}
+
+func (g *FakeGateway) QueryStatus(reference string) (*PaymentResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	order, ok := g.orders[reference]
+	if !ok {
+		return nil, fmt.Errorf("unknown fake order %s", reference)
+	}
+	return &PaymentResult{Status: order.Status, ProviderTxnID: order.TxnID, Amount: order.Amount, Currency: order.Currency}, nil
+}
+
+// VerifyWebhook accepts {"order_id": "...", "succeeded": true} and trusts it; the fake has no signatures.
+// An optional "amount" and "currency" override what was ordered, to simulate under- or overpayment.
+func (g *FakeGateway) VerifyWebhook(req WebhookRequest) (*WebhookEvent, error) {
+	var payload struct {
+		OrderID   string   `json:"order_id"`
+		Succeeded bool     `json:"succeeded"`
+		Amount    *float64 `json:"amount"`
+		Currency  string   `json:"currency"`
+	}
+	if err := json.Unmarshal(req.Body, &payload); err != nil {
+		return nil, err
+	}
+
+	order, err := g.Complete(payload.OrderID, payload.Succeeded)
+	if err != nil {
+		return nil, err
+	}
+	event := &WebhookEvent{
+		PaymentID:       order.PaymentID,
+		ProviderOrderID: payload.OrderID,
+		Succeeded:       payload.Succeeded,
+		ProviderTxnID:   order.TxnID,
+		Amount:          order.Amount,
+		Currency:        order.Currency,
+	}
+	if payload.Amount != nil {
+		event.Amount = *payload.Amount
+	}
+	if payload.Currency != "" {
+		event.Currency = payload.Currency
+	}
+	return event, nil
+}
@@ -0,0 +1,146 @@
+package payments
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrNotSupported = errors.New("this operation is not supported by the payment provider")
+
+type InitiateRequest struct {
+	PaymentID   string
+	Amount      float64
+	Currency    string
+	PhoneNumber string
+	Description string
+}
+
+// InitiateResult carries whichever provider references the payment should be stored under.
+// ProviderOrderID is the provider's handle for the payment attempt and is what QueryStatus takes.
+type InitiateResult struct {
+	ProviderOrderID   string
+	MerchantRequestID string
+	CustomerMessage   string
+}
+
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// PaymentResult is a provider's view of a payment. Amount and Currency are what was actually
+// paid, left empty when the provider does not report them.
+type PaymentResult struct {
+	Status        string
+	ProviderTxnID string
+	Amount        float64
+	Currency      string
+}
+
+// RefundRequest returns part or all of a captured payment. RefundID is our own reference and
+// doubles as the provider's idempotency key; PhoneNumber is needed by providers that pay out to a wallet.
+type RefundRequest struct {
+	RefundID      string
+	ProviderTxnID string
+	Amount        float64
+	Currency      string
+	PhoneNumber   string
+	Reason        string
+}
+
+// RefundResult reports a refund as succeeded, failed, or pending until the provider calls back.
+type RefundResult struct {
+	ProviderRefundID string
+	Status           string
+}
+
+// RefundEvent is a provider's asynchronous report on a refund it earlier accepted as pending.
+type RefundEvent struct {
+	// RefundID is the reference we sent with the refund, when the provider echoes it back.
+	RefundID         string
+	ProviderRefundID string
+	Succeeded        bool
+	Description      string
+}
+
+// RefundWebhookVerifier is implemented by gateways whose refunds complete through a callback.
+type RefundWebhookVerifier interface {
+	VerifyRefundWebhook(req WebhookRequest) (*RefundEvent, error)
+}
+
+type WebhookRequest struct {
+	Headers  http.Header
+	Query    url.Values
+	Body     []byte
+	RemoteIP string
+}
+
+// WebhookEvent is a provider callback reduced to what fulfilment needs. At least one of
+// PaymentID, ProviderOrderID or MerchantRequestID identifies the payment. Ignored is set
+// for event types that carry no payment outcome and only need acknowledging. Amount and
+// Currency are what the customer paid, when the callback reports it.
+type WebhookEvent struct {
+	PaymentID         string
+	ProviderOrderID   string
+	MerchantRequestID string
+	Succeeded         bool
+	ProviderTxnID     string
+	Amount            float64
+	Currency          string
+	Description       string
+	Ignored           bool
+}
+
+// Gateway is implemented by every external payment provider.
+type Gateway interface {
+	Name() string
+	// SettlementCurrency is the only currency the provider accepts, or "" if it takes any.
+	SettlementCurrency() string
+	Initiate(req InitiateRequest) (*InitiateResult, error)
+	Capture(providerOrderID string) (*PaymentResult, error)
+	Refund(req RefundRequest) (*RefundResult, error)
+	// VerifyWebhook authenticates and parses a callback. Authenticity failures wrap ErrWebhookRejected.
+	VerifyWebhook(req WebhookRequest) (*WebhookEvent, error)
+	// QueryStatus asks the provider directly for the outcome of a payment by its ProviderOrderID.
+	QueryStatus(reference string) (*PaymentResult, error)
+}
+
+var (
+	registryMutex sync.RWMutex
+	gateways      = map[string]Gateway{}
+)
+
+func Register(g Gateway) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	gateways[g.Name()] = g
+}
+
+func Get(name string) (Gateway, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	g, ok := gateways[strings.ToLower(name)]
+	return g, ok
+}
+
+// Names lists the registered providers, for error messages.
+func Names() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	names := make([]string, 0, len(gateways))
+	for name := range gateways {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register(MpesaGateway{})
+	Register(PayPalGateway{})
+}
@@ -0,0 +1,247 @@
+package payments
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	config "github.com/anjiri1684/language_tutor/configs"
+)
+
+// MpesaGateway collects KES payments by STK push through KCB Buni.
+type MpesaGateway struct{}
+
+func (MpesaGateway) Name() string { return "mpesa" }
+
+func (MpesaGateway) SettlementCurrency() string { return "KES" }
+
+func (MpesaGateway) Initiate(req InitiateRequest) (*InitiateResult, error) {
+	if req.PhoneNumber == "" {
+		return nil, ErrPhoneNumberRequired
+	}
+	stkResponse, err := InitiateMpesaSTKPush(req.Amount, req.PhoneNumber, req.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+	return &InitiateResult{
+		ProviderOrderID:   stkResponse.Response.CheckoutRequestID,
+		MerchantRequestID: stkResponse.Response.MerchantRequestID,
+		CustomerMessage:   stkResponse.Response.CustomerMessage,
+	}, nil
+}
+
+// Capture is not needed for M-Pesa: the customer approves the STK prompt and KCB calls back.
+func (MpesaGateway) Capture(providerOrderID string) (*PaymentResult, error) {
+	return nil, ErrNotSupported
+}
+
+// Refund pays the amount back to the customer's wallet by B2C, which also covers partial refunds.
+// KCB reports the outcome on the refund callback URL.
+func (MpesaGateway) Refund(req RefundRequest) (*RefundResult, error) {
+	if req.PhoneNumber == "" {
+		return nil, ErrPhoneNumberRequired
+	}
+	b2cResponse, err := InitiateMpesaB2C(req.Amount, req.PhoneNumber, req.RefundID, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+	return &RefundResult{ProviderRefundID: b2cResponse.Response.ConversationID, Status: StatusPending}, nil
+}
+
+type stkQueryResponse struct {
+	Response struct {
+		ResultCode interface{} `json:"ResultCode"`
+		ResultDesc string      `json:"ResultDesc"`
+	} `json:"response"`
+}
+
+// QueryStatus asks KCB for the outcome of an STK push by its CheckoutRequestID. The endpoint is
+// configured with KCB_STK_QUERY_URL; without it the query is reported as unsupported.
+func (MpesaGateway) QueryStatus(checkoutRequestID string) (*PaymentResult, error) {
+	queryURL := config.Config("KCB_STK_QUERY_URL")
+	if queryURL == "" {
+		return nil, ErrNotSupported
+	}
+	if checkoutRequestID == "" {
+		return nil, fmt.Errorf("no CheckoutRequestID to query")
+	}
+
+	accessToken, err := GetKcbAccessToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get KCB access token: %v", err)
+	}
+
+	body, _ := json.Marshal(map[string]string{"checkoutRequestID": checkoutRequestID})
+	req, err := http.NewRequest("POST", queryURL, bytes.NewBuffer(body))
+	if err != nil { return nil, fmt.Errorf("failed to create STK query request: %v", err) }
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("routeCode", config.Config("KCB_ROUTE_CODE"))
+	req.Header.Set("operation", "STKQuery")
+	req.Header.Set("messageId", fmt.Sprintf("%s_%d", checkoutRequestID, time.Now().UnixNano()))
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	client := &http.Client{ Timeout: 10 * time.Second }
+	resp, err := client.Do(req)
+	if err != nil { return nil, fmt.Errorf("failed to send STK query: %v", err) }
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("KCB STK query returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var queryResponse stkQueryResponse
+	if err := json.Unmarshal(respBody, &queryResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal STK query response: %v", err)
+	}
+
+	result := &PaymentResult{Status: StatusFailed}
+	switch code := fmt.Sprint(queryResponse.Response.ResultCode); code {
+	case "0":
+		result.Status = StatusSucceeded
+	case "", "<nil>", "4999", "500.001.1001":
+		// The request is still being processed.
+		result.Status = StatusPending
+	}
+	return result, nil
+}
+
+type KcbWebhookPayload struct {
+	Body struct {
+		StkCallback struct {
+			MerchantRequestID string `json:"MerchantRequestID"`
+			CheckoutRequestID string `json:"CheckoutRequestID"`
+			ResultCode        int    `json:"ResultCode"`
+			ResultDesc        string `json:"ResultDesc"`
+			CallbackMetadata  struct {
+				Item []struct {
+					Name  string      `json:"Name"`
+					Value interface{} `json:"Value"`
+				} `json:"Item"`
+			} `json:"CallbackMetadata"`
+			Reference string `json:"Reference"`
+		} `json:"stkCallback"`
+	} `json:"Body"`
+}
+
+type KcbB2CResultPayload struct {
+	Result struct {
+		ResultCode               interface{} `json:"ResultCode"`
+		ResultDesc               string      `json:"ResultDesc"`
+		ConversationID           string      `json:"ConversationID"`
+		OriginatorConversationID string      `json:"OriginatorConversationID"`
+		TransactionID            string      `json:"TransactionID"`
+	} `json:"Result"`
+}
+
+func mpesaRefundCallbackURL() string {
+	callbackURL := config.Config("WEBHOOK_BASE_URL") + "/api/v1/payments/refund-webhook/mpesa"
+	if secret := config.Config("MPESA_WEBHOOK_SECRET"); secret != "" {
+		callbackURL += "?secret=" + url.QueryEscape(secret)
+	}
+	return callbackURL
+}
+
+// VerifyRefundWebhook handles B2C results. There is no status query to fall back on, so the
+// shared secret is required.
+func (g MpesaGateway) VerifyRefundWebhook(req WebhookRequest) (*RefundEvent, error) {
+	if err := checkSourceIP(g.Name(), req.RemoteIP); err != nil {
+		return nil, err
+	}
+	secret := config.Config("MPESA_WEBHOOK_SECRET")
+	if secret == "" {
+		return nil, rejectWebhook("M-Pesa refund callbacks cannot be verified; set MPESA_WEBHOOK_SECRET")
+	}
+	if err := checkSharedSecret(req, secret); err != nil {
+		return nil, err
+	}
+
+	var payload KcbB2CResultPayload
+	if err := json.Unmarshal(req.Body, &payload); err != nil {
+		return nil, fmt.Errorf("cannot parse KCB B2C result: %v", err)
+	}
+	return &RefundEvent{
+		RefundID:         payload.Result.OriginatorConversationID,
+		ProviderRefundID: payload.Result.ConversationID,
+		Succeeded:        fmt.Sprint(payload.Result.ResultCode) == "0",
+		Description:      payload.Result.ResultDesc,
+	}, nil
+}
+
+// mpesaCallbackURL carries the shared secret as a query parameter so KCB echoes it back on every callback.
+func mpesaCallbackURL() string {
+	callbackURL := config.Config("WEBHOOK_BASE_URL") + "/api/v1/payments/webhook"
+	if secret := config.Config("MPESA_WEBHOOK_SECRET"); secret != "" {
+		callbackURL += "?secret=" + url.QueryEscape(secret)
+	}
+	return callbackURL
+}
+
+// VerifyWebhook checks the source address and the shared secret echoed in the callback URL.
+// The outcome is confirmed separately with QueryStatus before a payment is fulfilled.
+func (g MpesaGateway) VerifyWebhook(req WebhookRequest) (*WebhookEvent, error) {
+	if err := checkSourceIP(g.Name(), req.RemoteIP); err != nil {
+		return nil, err
+	}
+	if secret := config.Config("MPESA_WEBHOOK_SECRET"); secret != "" {
+		if err := checkSharedSecret(req, secret); err != nil {
+			return nil, err
+		}
+	} else if config.Config("KCB_STK_QUERY_URL") == "" {
+		return nil, rejectWebhook("M-Pesa callbacks cannot be verified; set MPESA_WEBHOOK_SECRET or KCB_STK_QUERY_URL")
+	}
+
+	var payload KcbWebhookPayload
+	if err := json.Unmarshal(req.Body, &payload); err != nil {
+		return nil, fmt.Errorf("cannot parse KCB callback: %v", err)
+	}
+	stk := payload.Body.StkCallback
+
+	// The STK invoice number is "<account>-<payment id>", and the payment id has dashes of its own.
+	paymentRefID := stk.Reference
+	if _, suffix, found := strings.Cut(stk.Reference, "-"); found {
+		paymentRefID = suffix
+	}
+
+	event := &WebhookEvent{
+		PaymentID:         paymentRefID,
+		ProviderOrderID:   stk.CheckoutRequestID,
+		MerchantRequestID: stk.MerchantRequestID,
+		Succeeded:         stk.ResultCode == 0,
+		Description:       stk.ResultDesc,
+	}
+	for _, item := range stk.CallbackMetadata.Item {
+		switch item.Name {
+		case "MpesaReceiptNumber":
+			if val, ok := item.Value.(string); ok {
+				event.ProviderTxnID = val
+			}
+		case "Amount":
+			if amount, ok := metadataAmount(item.Value); ok {
+				event.Amount = amount
+				event.Currency = g.SettlementCurrency()
+			}
+		}
+	}
+	return event, nil
+}
+
+// metadataAmount reads the Amount callback item, which KCB sends as a number but some
+// environments send as a string.
+func metadataAmount(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		amount, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return amount, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,42 @@
+package payments
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestMpesaWebhookTakesPaymentIDFromInvoiceNumber(t *testing.T) {
+	t.Setenv("MPESA_WEBHOOK_SECRET", "s3cret")
+	t.Setenv("MPESA_WEBHOOK_ALLOWED_IPS", "")
+
+	paymentID := "3f2b8c1e-9d4a-4e7b-a1c2-5d6e7f8a9b0c"
+	body := fmt.Sprintf(`{"Body": {"stkCallback": {
+		"MerchantRequestID": "29115-34620561-1",
+		"CheckoutRequestID": "ws_CO_191220191020363925",
+		"ResultCode": 0,
+		"ResultDesc": "The service request is processed successfully.",
+		"Reference": "LANGTUTOR-%s",
+		"CallbackMetadata": {"Item": [
+			{"Name": "Amount", "Value": 1500},
+			{"Name": "MpesaReceiptNumber", "Value": "NLJ7RT61SV"}
+		]}
+	}}}`, paymentID)
+
+	event, err := MpesaGateway{}.VerifyWebhook(WebhookRequest{
+		Query: url.Values{"secret": {"s3cret"}},
+		Body:  []byte(body),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.PaymentID != paymentID {
+		t.Fatalf("payment id = %q, want %q", event.PaymentID, paymentID)
+	}
+	if event.ProviderOrderID != "ws_CO_191220191020363925" || event.MerchantRequestID != "29115-34620561-1" {
+		t.Fatalf("lost the KCB request ids: %+v", event)
+	}
+	if !event.Succeeded || event.ProviderTxnID != "NLJ7RT61SV" || event.Amount != 1500 || event.Currency != "KES" {
+		t.Fatalf("unexpected outcome: %+v", event)
+	}
+}
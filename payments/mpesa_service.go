@@ -47,6 +47,9 @@ type StkPushResponse struct {
 
 var nonNumericRegex = regexp.MustCompile(`[^0-9]`)
 
+var ErrInvalidPhoneNumber = errors.New("invalid M-Pesa phone number format")
+var ErrPhoneNumberRequired = errors.New("M-Pesa phone number is required")
+
 func SanitizeMpesaNumber(phone string) (string, error) {
 	sanitized := nonNumericRegex.ReplaceAllString(phone, "")
 
@@ -60,7 +63,7 @@ func SanitizeMpesaNumber(phone string) (string, error) {
 		return sanitized, nil
 	}
 
-	return "", errors.New("invalid M-Pesa phone number format")
+	return "", ErrInvalidPhoneNumber
 }
 
 func InitiateMpesaSTKPush(amount float64, phoneNumber string, paymentRefID string) (*StkPushResponse, error) {
@@ -74,7 +77,7 @@ func InitiateMpesaSTKPush(amount float64, phoneNumber string, paymentRefID strin
 		return nil, err
 	}
 
-	callbackURL := config.Config("WEBHOOK_BASE_URL") + "/api/v1/payments/webhook"
+	callbackURL := mpesaCallbackURL()
 	amountStr := strconv.FormatFloat(amount, 'f', 0, 64)
 
 	kcbAccount := config.Config("KCB_ACCOUNT_NUMBER")
@@ -135,4 +138,89 @@ func InitiateMpesaSTKPush(amount float64, phoneNumber string, paymentRefID strin
 
 	log.Println("✅ STK Push initiated successfully for payment:", paymentRefID)
 	return &stkResponse, nil
-}
\ No newline at end of file
+}
+
+type B2CRequest struct {
+	PhoneNumber  string `json:"phoneNumber"`
+	Amount       string `json:"amount"`
+	Reference    string `json:"reference"`
+	OrgShortCode string `json:"orgShortCode"`
+	Remarks      string `json:"remarks"`
+	ResultURL    string `json:"resultUrl"`
+}
+
+type B2CResponse struct {
+	Header struct {
+		StatusCode        string `json:"statusCode"`
+		StatusDescription string `json:"statusDescription"`
+	} `json:"header"`
+	Response struct {
+		ConversationID           string `json:"ConversationID"`
+		OriginatorConversationID string `json:"OriginatorConversationID"`
+		ResponseCode             string `json:"ResponseCode"`
+		ResponseDescription      string `json:"ResponseDescription"`
+	} `json:"response"`
+}
+
+// InitiateMpesaB2C pays amount out to a customer's M-Pesa wallet. The endpoint is configured with
+// KCB_B2C_URL; the outcome arrives later on the refund callback URL.
+func InitiateMpesaB2C(amount float64, phoneNumber, reference, remarks string) (*B2CResponse, error) {
+	b2cURL := config.Config("KCB_B2C_URL")
+	if b2cURL == "" {
+		return nil, ErrNotSupported
+	}
+
+	sanitizedPhone, err := SanitizeMpesaNumber(phoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := GetKcbAccessToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get KCB access token: %v", err)
+	}
+
+	payload := B2CRequest{
+		PhoneNumber:  sanitizedPhone,
+		Amount:       strconv.FormatFloat(amount, 'f', 0, 64),
+		Reference:    reference,
+		OrgShortCode: config.Config("KCB_B2C_SHORTCODE"),
+		Remarks:      remarks,
+		ResultURL:    mpesaRefundCallbackURL(),
+	}
+	body, err := json.Marshal(payload)
+	if err != nil { return nil, fmt.Errorf("failed to marshal B2C payload: %v", err) }
+
+	req, err := http.NewRequest("POST", b2cURL, bytes.NewBuffer(body))
+	if err != nil { return nil, fmt.Errorf("failed to create B2C request: %v", err) }
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("routeCode", config.Config("KCB_ROUTE_CODE"))
+	req.Header.Set("operation", "B2C")
+	req.Header.Set("messageId", fmt.Sprintf("%s_%d", reference, time.Now().UnixNano()))
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	client := &http.Client{ Timeout: 10 * time.Second }
+	resp, err := client.Do(req)
+	if err != nil { return nil, fmt.Errorf("failed to send B2C request: %v", err) }
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil { return nil, fmt.Errorf("failed to read B2C response body: %v", err) }
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("KCB B2C API Error: %s", string(respBody))
+		return nil, fmt.Errorf("KCB Buni B2C returned non-200 status: %d", resp.StatusCode)
+	}
+
+	var b2cResponse B2CResponse
+	if err := json.Unmarshal(respBody, &b2cResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal B2C response: %v", err)
+	}
+	if b2cResponse.Response.ResponseCode != "0" {
+		return nil, fmt.Errorf("KCB B2C failed: %s", b2cResponse.Response.ResponseDescription)
+	}
+
+	log.Println("✅ B2C payout initiated for refund:", reference)
+	return &b2cResponse, nil
+}
@@ -0,0 +1,126 @@
+package payments
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// PayPalGateway takes card and PayPal balance payments through PayPal Checkout orders.
+type PayPalGateway struct{}
+
+func (PayPalGateway) Name() string { return "paypal" }
+
+func (PayPalGateway) SettlementCurrency() string { return "" }
+
+func (PayPalGateway) Initiate(req InitiateRequest) (*InitiateResult, error) {
+	order, err := CreatePayPalOrder(req.Amount, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	return &InitiateResult{ProviderOrderID: order.ID}, nil
+}
+
+func (PayPalGateway) Capture(providerOrderID string) (*PaymentResult, error) {
+	order, err := CapturePayPalOrder(providerOrderID)
+	if err != nil {
+		return nil, err
+	}
+	return paypalOrderResult(order), nil
+}
+
+// Refund returns money against the payment's capture, which is what CompletePayment stores as the ProviderTxnID.
+func (PayPalGateway) Refund(req RefundRequest) (*RefundResult, error) {
+	if req.ProviderTxnID == "" {
+		return nil, fmt.Errorf("payment has no PayPal capture to refund")
+	}
+	refund, err := RefundPayPalCapture(req.ProviderTxnID, req.Amount, req.Currency, req.Reason, req.RefundID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RefundResult{ProviderRefundID: refund.ID, Status: StatusPending}
+	switch refund.Status {
+	case "COMPLETED":
+		result.Status = StatusSucceeded
+	case "FAILED", "CANCELLED":
+		result.Status = StatusFailed
+	}
+	return result, nil
+}
+
+func (PayPalGateway) QueryStatus(reference string) (*PaymentResult, error) {
+	order, err := GetPayPalOrder(reference)
+	if err != nil {
+		return nil, err
+	}
+	return paypalOrderResult(order), nil
+}
+
+func paypalOrderResult(order *PayPalOrder) *PaymentResult {
+	result := &PaymentResult{Status: StatusPending, ProviderTxnID: order.CaptureID()}
+	result.Amount, result.Currency = order.CapturedAmount()
+	switch order.Status {
+	case "COMPLETED":
+		result.Status = StatusSucceeded
+	case "VOIDED":
+		result.Status = StatusFailed
+	}
+	return result
+}
+
+type paypalWebhookEvent struct {
+	EventType string `json:"event_type"`
+	Resource  struct {
+		ID                string       `json:"id"`
+		Status            string       `json:"status"`
+		Amount            PayPalAmount `json:"amount"`
+		SupplementaryData struct {
+			RelatedIDs struct {
+				OrderID string `json:"order_id"`
+			} `json:"related_ids"`
+		} `json:"supplementary_data"`
+	} `json:"resource"`
+}
+
+// VerifyWebhook checks the source address and PayPal's transmission signature, then handles
+// capture outcome events; anything else is acknowledged and ignored.
+func (g PayPalGateway) VerifyWebhook(req WebhookRequest) (*WebhookEvent, error) {
+	if err := checkSourceIP(g.Name(), req.RemoteIP); err != nil {
+		return nil, err
+	}
+	if req.Headers.Get("PAYPAL-TRANSMISSION-SIG") == "" {
+		return nil, rejectWebhook("missing PayPal transmission signature")
+	}
+	verified, err := VerifyPayPalWebhookSignature(req.Headers, req.Body)
+	if err != nil {
+		return nil, rejectWebhook("could not verify PayPal signature: %v", err)
+	}
+	if !verified {
+		return nil, rejectWebhook("PayPal signature verification failed")
+	}
+
+	var payload paypalWebhookEvent
+	if err := json.Unmarshal(req.Body, &payload); err != nil {
+		return nil, fmt.Errorf("cannot parse PayPal webhook: %v", err)
+	}
+
+	event := &WebhookEvent{
+		ProviderOrderID: payload.Resource.SupplementaryData.RelatedIDs.OrderID,
+		ProviderTxnID:   payload.Resource.ID,
+		Description:     payload.EventType,
+	}
+	switch payload.EventType {
+	case "PAYMENT.CAPTURE.COMPLETED":
+		event.Succeeded = true
+		if amount, err := strconv.ParseFloat(payload.Resource.Amount.Value, 64); err == nil {
+			event.Amount = amount
+			event.Currency = payload.Resource.Amount.CurrencyCode
+		}
+	case "PAYMENT.CAPTURE.DENIED", "PAYMENT.CAPTURE.DECLINED":
+		event.Succeeded = false
+	default:
+		event.Ignored = true
+	}
+	return event, nil
+}
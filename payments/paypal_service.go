@@ -6,14 +6,59 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	config "github.com/anjiri1684/language_tutor/configs"
 )
 
 type PayPalOrder struct {
-	ID     string `json:"id"`
-	Status string `json:"status"`
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	PurchaseUnits []struct {
+		Payments struct {
+			Captures []struct {
+				ID     string       `json:"id"`
+				Status string       `json:"status"`
+				Amount PayPalAmount `json:"amount"`
+			} `json:"captures"`
+		} `json:"payments"`
+	} `json:"purchase_units"`
+}
+
+// CaptureID returns the ID of the order's first capture, which is what PayPal refunds are issued against.
+func (o *PayPalOrder) CaptureID() string {
+	for _, unit := range o.PurchaseUnits {
+		for _, capture := range unit.Payments.Captures {
+			return capture.ID
+		}
+	}
+	return ""
+}
+
+type PayPalAmount struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+// CapturedAmount totals the order's completed captures. The currency is empty if nothing was captured.
+func (o *PayPalOrder) CapturedAmount() (float64, string) {
+	var total float64
+	currency := ""
+	for _, unit := range o.PurchaseUnits {
+		for _, capture := range unit.Payments.Captures {
+			if capture.Status != "COMPLETED" {
+				continue
+			}
+			value, err := strconv.ParseFloat(capture.Amount.Value, 64)
+			if err != nil {
+				continue
+			}
+			total += value
+			currency = capture.Amount.CurrencyCode
+		}
+	}
+	return total, currency
 }
 
 type accessTokenResponse struct {
@@ -109,4 +154,121 @@ func CapturePayPalOrder(orderID string) (*PayPalOrder, error) {
 	var order PayPalOrder
 	json.NewDecoder(resp.Body).Decode(&order)
 	return &order, nil
-}
\ No newline at end of file
+}
+
+func GetPayPalOrder(orderID string) (*PayPalOrder, error) {
+	accessToken, err := getPayPalAccessToken()
+	if err != nil { return nil, err }
+
+	apiBase := config.Config("PAYPAL_API_BASE_URL")
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/v2/checkout/orders/%s", apiBase, orderID), nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil { return nil, err }
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to get order: %s", string(respBody))
+	}
+
+	var order PayPalOrder
+	json.NewDecoder(resp.Body).Decode(&order)
+	return &order, nil
+}
+
+// VerifyPayPalWebhookSignature asks PayPal to check a webhook's transmission signature
+// against the webhook registered as PAYPAL_WEBHOOK_ID.
+func VerifyPayPalWebhookSignature(headers http.Header, body []byte) (bool, error) {
+	webhookID := config.Config("PAYPAL_WEBHOOK_ID")
+	if webhookID == "" {
+		return false, fmt.Errorf("PAYPAL_WEBHOOK_ID is not set")
+	}
+
+	accessToken, err := getPayPalAccessToken()
+	if err != nil { return false, err }
+
+	apiBase := config.Config("PAYPAL_API_BASE_URL")
+
+	payload := map[string]interface{}{
+		"auth_algo":         headers.Get("PAYPAL-AUTH-ALGO"),
+		"cert_url":          headers.Get("PAYPAL-CERT-URL"),
+		"transmission_id":   headers.Get("PAYPAL-TRANSMISSION-ID"),
+		"transmission_sig":  headers.Get("PAYPAL-TRANSMISSION-SIG"),
+		"transmission_time": headers.Get("PAYPAL-TRANSMISSION-TIME"),
+		"webhook_id":        webhookID,
+		"webhook_event":     json.RawMessage(body),
+	}
+	reqBody, err := json.Marshal(payload)
+	if err != nil { return false, err }
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/v1/notifications/verify-webhook-signature", apiBase), bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil { return false, err }
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("failed to verify webhook signature: %s", string(respBody))
+	}
+
+	var verification struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&verification); err != nil {
+		return false, err
+	}
+	return verification.VerificationStatus == "SUCCESS", nil
+}
+
+type PayPalRefund struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// RefundPayPalCapture refunds amount of a capture. requestID is sent as PayPal-Request-Id so a
+// retried call cannot refund twice.
+func RefundPayPalCapture(captureID string, amount float64, currency, note, requestID string) (*PayPalRefund, error) {
+	accessToken, err := getPayPalAccessToken()
+	if err != nil { return nil, err }
+
+	apiBase := config.Config("PAYPAL_API_BASE_URL")
+
+	payload := map[string]interface{}{
+		"amount": map[string]string{
+			"currency_code": currency,
+			"value":         fmt.Sprintf("%.2f", amount),
+		},
+	}
+	if note != "" {
+		payload["note_to_payer"] = note
+	}
+	body, _ := json.Marshal(payload)
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/v2/payments/captures/%s/refund", apiBase, captureID), bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	if requestID != "" {
+		req.Header.Set("PayPal-Request-Id", requestID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil { return nil, err }
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to refund capture: %s", string(respBody))
+	}
+
+	var refund PayPalRefund
+	if err := json.NewDecoder(resp.Body).Decode(&refund); err != nil {
+		return nil, fmt.Errorf("failed to decode refund response: %v", err)
+	}
+	return &refund, nil
+}
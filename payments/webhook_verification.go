@@ -0,0 +1,56 @@
+package payments
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	config "github.com/anjiri1684/language_tutor/configs"
+)
+
+// ErrWebhookRejected marks callbacks that failed an authenticity check, as opposed to ones that could not be parsed.
+var ErrWebhookRejected = errors.New("webhook rejected")
+
+func rejectWebhook(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrWebhookRejected, fmt.Sprintf(format, args...))
+}
+
+// checkSourceIP enforces <PROVIDER>_WEBHOOK_ALLOWED_IPS, a comma separated list of addresses or CIDR ranges.
+// An empty list allows any source.
+func checkSourceIP(provider, remoteIP string) error {
+	allowed := strings.TrimSpace(config.Config(strings.ToUpper(provider) + "_WEBHOOK_ALLOWED_IPS"))
+	if allowed == "" {
+		return nil
+	}
+
+	ip := net.ParseIP(remoteIP)
+	if ip == nil {
+		return rejectWebhook("unparseable source address %q", remoteIP)
+	}
+	for _, entry := range strings.Split(allowed, ",") {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+				return nil
+			}
+		} else if allowedIP := net.ParseIP(entry); allowedIP != nil && allowedIP.Equal(ip) {
+			return nil
+		}
+	}
+	return rejectWebhook("source address %s is not allow-listed", remoteIP)
+}
+
+// checkSharedSecret compares the secret sent in the X-Webhook-Secret header or the "secret"
+// query parameter against the configured one in constant time.
+func checkSharedSecret(req WebhookRequest, secret string) error {
+	sent := req.Headers.Get("X-Webhook-Secret")
+	if sent == "" {
+		sent = req.Query.Get("secret")
+	}
+	if sent == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(secret)) != 1 {
+		return rejectWebhook("missing or invalid shared secret")
+	}
+	return nil
+}
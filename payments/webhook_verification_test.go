@@ -0,0 +1,61 @@
+package payments
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestCheckSourceIP(t *testing.T) {
+	t.Setenv("TESTPAY_WEBHOOK_ALLOWED_IPS", "196.201.214.200, 10.0.0.0/8")
+
+	for ip, allowed := range map[string]bool{
+		"196.201.214.200": true,
+		"10.1.2.3":        true,
+		"196.201.214.201": false,
+		"11.0.0.1":        false,
+		"not-an-ip":       false,
+		"":                false,
+	} {
+		err := checkSourceIP("testpay", ip)
+		if allowed && err != nil {
+			t.Errorf("%q: unexpected error %v", ip, err)
+		}
+		if !allowed && !errors.Is(err, ErrWebhookRejected) {
+			t.Errorf("%q: got %v, want ErrWebhookRejected", ip, err)
+		}
+	}
+}
+
+func TestCheckSourceIPWithoutAllowList(t *testing.T) {
+	t.Setenv("TESTPAY_WEBHOOK_ALLOWED_IPS", "")
+	if err := checkSourceIP("testpay", "203.0.113.9"); err != nil {
+		t.Fatalf("an empty allow-list should accept any source, got %v", err)
+	}
+}
+
+func TestCheckSharedSecret(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Webhook-Secret", "s3cret")
+
+	cases := []struct {
+		name string
+		req  WebhookRequest
+		ok   bool
+	}{
+		{"header", WebhookRequest{Headers: header}, true},
+		{"query", WebhookRequest{Headers: http.Header{}, Query: url.Values{"secret": {"s3cret"}}}, true},
+		{"wrong", WebhookRequest{Headers: http.Header{}, Query: url.Values{"secret": {"guess"}}}, false},
+		{"missing", WebhookRequest{Headers: http.Header{}}, false},
+	}
+	for _, tc := range cases {
+		err := checkSharedSecret(tc.req, "s3cret")
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if !tc.ok && !errors.Is(err, ErrWebhookRejected) {
+			t.Errorf("%s: got %v, want ErrWebhookRejected", tc.name, err)
+		}
+	}
+}
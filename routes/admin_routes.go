@@ -39,6 +39,10 @@ func AdminRoutes(app *fiber.App) {
 
 	admin.Get("/bookings", handlers.AdminGetAllBookings)
 	admin.Get("/payments", handlers.AdminGetPayments)
+	admin.Get("/payments/webhook-rejections", handlers.AdminGetWebhookRejections)
+	admin.Post("/payments/:paymentId/resolve", handlers.ResolvePaymentReview)
+	admin.Get("/payments/:paymentId/refunds", handlers.ListPaymentRefunds)
+	admin.Post("/payments/:paymentId/refunds", handlers.IssuePaymentRefund)
 	
 
 	reviews := admin.Group("/reviews")
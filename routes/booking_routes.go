@@ -13,12 +13,35 @@ func BookingRoutes(app *fiber.App) {
 
 	booking := api.Group("/bookings", middleware.Protected())
 	booking.Get("/me", handlers.GetMyBookings)
-	booking.Post("", handlers.CreateBooking)
+	booking.Post("", middleware.Idempotency(), handlers.CreateBooking)
 	booking.Post("/:bookingId/review", handlers.CreateReview) 
 	booking.Post("/:bookingId/request-refund", handlers.RequestRefund) 
+	booking.Post("/:bookingId/cancel", handlers.CancelBooking)
 	booking.Post("/:bookingId/request-reschedule", handlers.RequestReschedule)
+	booking.Post("/:bookingId/reschedule-response", handlers.RespondToRescheduleProposal)
+	booking.Get("/:bookingId/history", handlers.GetBookingStatusHistory)
+	booking.Post("/:bookingId/attendance/join", handlers.JoinClass)
+	booking.Post("/:bookingId/attendance/leave", handlers.LeaveClass)
+	booking.Get("/:bookingId/report", handlers.GetLessonReport)
+
+	homework := api.Group("/homework", middleware.Protected())
+	homework.Get("/me", handlers.GetMyHomework)
+	homework.Post("/:homeworkId/submit", handlers.SubmitHomework)
+
+	api.Post("/meetings/webhook/:provider", handlers.HandleMeetingWebhook)
+
+	waitlist := api.Group("/waitlist", middleware.Protected())
+	waitlist.Get("/me", handlers.GetMyWaitlistEntries)
+	waitlist.Post("/slots/:slotId", handlers.JoinWaitlist)
+	waitlist.Delete("/:entryId", handlers.LeaveWaitlist)
 
 	teacherBooking := api.Group("/teacher/bookings", middleware.Protected(), middleware.TeacherRequired())
 	teacherBooking.Post("/:bookingId/complete", handlers.MarkBookingAsComplete)
 	teacherBooking.Post("/:bookingId/feedback", handlers.SubmitTeacherFeedback)
+	teacherBooking.Put("/:bookingId/report", handlers.SaveLessonReport)
+	teacherBooking.Post("/:bookingId/cancel", handlers.TeacherCancelBooking)
+
+	teacherHomework := api.Group("/teacher/homework", middleware.Protected(), middleware.TeacherRequired())
+	teacherHomework.Get("", handlers.GetTeacherHomework)
+	teacherHomework.Post("/:homeworkId/grade", handlers.GradeHomework)
 }
\ No newline at end of file
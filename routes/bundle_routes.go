@@ -12,7 +12,7 @@ func BundleRoutes(app *fiber.App) {
 	
 	studentBundles := api.Group("/bundles", middleware.Protected())
 	studentBundles.Get("/me", handlers.GetMyBundles)
-	studentBundles.Post("/:bundleId/purchase", handlers.PurchaseBundle)
+	studentBundles.Post("/:bundleId/purchase", middleware.Idempotency(), handlers.PurchaseBundle)
 
 
 	adminBundles := api.Group("/admin/bundles", middleware.Protected(), middleware.AdminRequired())
@@ -11,8 +11,10 @@ func PaymentRoutes(app *fiber.App) {
 	api := app.Group("/api/v1")
 
 	api.Post("/payments/webhook", handlers.HandlePaymentWebhook)
+	api.Post("/payments/webhook/:provider", handlers.HandlePaymentWebhook)
+	api.Post("/payments/refund-webhook/:provider", handlers.HandleRefundWebhook)
 	
 	paypal := api.Group("/payments/paypal", middleware.Protected())
 	paypal.Post("/create-order/:paymentId", handlers.CreatePayPalOrderHandler)
-	paypal.Post("/capture-order", handlers.CapturePayPalOrderHandler) 
+	paypal.Post("/capture-order", middleware.Idempotency(), handlers.CapturePayPalOrderHandler) 
 }
\ No newline at end of file
@@ -13,5 +13,7 @@ func ProfileRoutes(app *fiber.App) {
 	profile.Get("", handlers.GetProfile)
 	profile.Put("", handlers.UpdateProfile)
 	profile.Get("/progress", handlers.GetMyProgress)
+	profile.Get("/calendar-feed", handlers.GetMyCalendarFeed)
+	profile.Post("/calendar-feed/rotate", handlers.RotateMyCalendarFeed)
 
 }
\ No newline at end of file
@@ -10,5 +10,8 @@ func PublicRoutes(app *fiber.App) {
 
 	api.Get("/locales/:lang", handlers.GetLocale)
 	api.Get("/currency/rate", handlers.GetConversionRate) 
+	api.Get("/cancellation-policy", handlers.GetCancellationPolicy)
+	api.Get("/calendar/:token", handlers.GetCalendarFeed)
+	api.Get("/availability/search", handlers.SearchAvailability)
 
 }
\ No newline at end of file
@@ -27,24 +27,32 @@ func TeacherRoutes(app *fiber.App) {
 	
 	availability := teacher.Group("/availability", middleware.TeacherRequired())
 	availability.Post("", handlers.CreateAvailabilitySlot)
+	availability.Post("/bulk", handlers.CreateAvailabilitySlotsBulk)
 	availability.Get("/me", handlers.GetMyAvailability)
 	availability.Delete("/:slotId", handlers.DeleteAvailabilitySlot) 
+	availability.Post("/rules", handlers.CreateAvailabilityRule)
+	availability.Get("/rules", handlers.GetMyAvailabilityRules)
+	availability.Put("/rules/:ruleId", handlers.UpdateAvailabilityRule)
+	availability.Delete("/rules/:ruleId", handlers.DeleteAvailabilityRule)
 
 	profile := teacher.Group("/profile")
 	profile.Get("/me", handlers.GetMyTeacherProfile)
 	profile.Put("/me", handlers.UpdateMyTeacherProfile)
+	profile.Put("/me/trial", handlers.UpdateMyTrialSettings)
 	
 
 	teacherLanguages := teacher.Group("/languages", middleware.TeacherRequired())
 	teacherLanguages.Post("", handlers.AddLanguageToProfile)
 	teacherLanguages.Delete("/:languageId", handlers.RemoveLanguageFromProfile)
+	teacherLanguages.Put("/:languageId/rate", handlers.SetMyLanguageRate)
+	teacherLanguages.Delete("/:languageId/rate", handlers.ClearMyLanguageRate)
 
 	reschedule := teacher.Group("/reschedule-requests")
 	reschedule.Get("", handlers.ListRescheduleRequests)
 	reschedule.Post("/:bookingId/process", handlers.ProcessReschedule)
 
 	payouts := teacher.Group("/payouts", middleware.TeacherRequired())
-	payouts.Post("/request", handlers.RequestPayout)
+	payouts.Post("/request", middleware.Idempotency(), handlers.RequestPayout)
 	payouts.Get("/requests", handlers.GetMyPayoutRequests) 
 
 }
\ No newline at end of file
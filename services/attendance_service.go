@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/services/bookingstate"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const attendanceEarlyJoin = 15 * time.Minute
+
+var ErrNotAParticipant = errors.New("you are not a participant in this class")
+var ErrOutsideClassWindow = errors.New("attendance can only be recorded from 15 minutes before the class until it ends")
+
+// RecordAttendance stores a join or leave event for one of the booking's participants.
+// The booking must have its AvailabilitySlot loaded.
+func RecordAttendance(tx *gorm.DB, booking models.Booking, userID uuid.UUID, event, source string, at time.Time) (models.AttendanceEvent, error) {
+	var role string
+	switch userID {
+	case booking.StudentID:
+		role = "student"
+	case booking.TeacherID:
+		role = "teacher"
+	default:
+		return models.AttendanceEvent{}, ErrNotAParticipant
+	}
+
+	slot := booking.AvailabilitySlot
+	if at.Before(slot.StartTime.Add(-attendanceEarlyJoin)) || at.After(slot.EndTime) {
+		return models.AttendanceEvent{}, ErrOutsideClassWindow
+	}
+
+	record := models.AttendanceEvent{
+		BookingID:  booking.ID,
+		UserID:     userID,
+		Role:       role,
+		Event:      event,
+		Source:     source,
+		OccurredAt: at,
+	}
+	return record, tx.Create(&record).Error
+}
+
+// ClassifyAttendance decides a finished class's outcome from who joined it.
+func ClassifyAttendance(tx *gorm.DB, booking models.Booking) (string, error) {
+	var roles []string
+	if err := tx.Model(&models.AttendanceEvent{}).
+		Where("booking_id = ? AND event = ?", booking.ID, "join").
+		Distinct("role").
+		Pluck("role", &roles).Error; err != nil {
+		return "", err
+	}
+
+	joined := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		joined[role] = true
+	}
+
+	switch {
+	case joined["student"] && joined["teacher"]:
+		return bookingstate.Completed, nil
+	case joined["teacher"]:
+		return bookingstate.StudentNoShow, nil
+	case joined["student"]:
+		return bookingstate.TeacherNoShow, nil
+	default:
+		return bookingstate.Unattended, nil
+	}
+}
+
+// CreditTeacherEarnings adds the teacher's share of a booking to their balance.
+func CreditTeacherEarnings(tx *gorm.DB, booking models.Booking) error {
+	return tx.Model(&models.Teacher{}).Where("user_id = ?", booking.TeacherID).
+		Update("current_balance", gorm.Expr("current_balance + ?", TeacherEarnings(booking))).Error
+}
+
+// SettleAttendance moves a finished booking to outcome and applies its payout and refund rules:
+// completed classes and student no-shows pay the teacher, teacher no-shows refund the student in full to credit.
+func SettleAttendance(tx *gorm.DB, booking *models.Booking, outcome string) error {
+	reasons := map[string]string{
+		bookingstate.Completed:     "Both participants attended",
+		bookingstate.StudentNoShow: "The student did not join the class",
+		bookingstate.TeacherNoShow: "The teacher did not join the class",
+		bookingstate.Unattended:    "Neither participant joined the class",
+	}
+	if err := bookingstate.Transition(tx, booking, outcome, bookingstate.System, reasons[outcome]); err != nil {
+		return err
+	}
+
+	switch outcome {
+	case bookingstate.Completed, bookingstate.StudentNoShow:
+		return CreditTeacherEarnings(tx, *booking)
+	case bookingstate.TeacherNoShow:
+		if _, err := RefundBooking(tx, booking, reasons[outcome], 100, "credit"); err != nil {
+			return err
+		}
+		return tx.Model(&models.Teacher{}).Where("user_id = ?", booking.TeacherID).
+			Update("no_show_count", gorm.Expr("no_show_count + 1")).Error
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	config "github.com/anjiri1684/language_tutor/configs"
+	"github.com/anjiri1684/language_tutor/models"
+	"gorm.io/gorm"
+)
+
+const defaultAvailabilityWeeksAhead = 4
+
+func AvailabilityWeeksAhead() int {
+	weeks, err := strconv.Atoi(config.Config("AVAILABILITY_WEEKS_AHEAD"))
+	if err != nil || weeks <= 0 {
+		return defaultAvailabilityWeeksAhead
+	}
+	return weeks
+}
+
+func parseRuleDays(days string) map[time.Weekday]bool {
+	result := make(map[time.Weekday]bool)
+	for _, d := range strings.Split(days, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(d))
+		if err == nil && n >= 0 && n <= 6 {
+			result[time.Weekday(n)] = true
+		}
+	}
+	return result
+}
+
+func parseRuleExceptions(exceptions string) map[string]bool {
+	result := make(map[string]bool)
+	for _, e := range strings.Split(exceptions, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			result[e] = true
+		}
+	}
+	return result
+}
+
+func parseLocalClock(value string) (int, int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid local time %q", value)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// MaterializeRule creates AvailabilitySlot rows for every occurrence of the rule
+// between now and until, skipping exceptions and times the teacher already has a slot for.
+func MaterializeRule(tx *gorm.DB, rule models.AvailabilityRule, until time.Time) (int, error) {
+	loc, err := time.LoadLocation(rule.TimeZone)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time zone %q", rule.TimeZone)
+	}
+	startHour, startMinute, err := parseLocalClock(rule.StartLocalTime)
+	if err != nil {
+		return 0, err
+	}
+	endHour, endMinute, err := parseLocalClock(rule.EndLocalTime)
+	if err != nil {
+		return 0, err
+	}
+
+	days := parseRuleDays(rule.DaysOfWeek)
+	exceptions := parseRuleExceptions(rule.Exceptions)
+
+	now := time.Now()
+	from := rule.EffectiveFrom.In(loc)
+	if nowLocal := now.In(loc); nowLocal.After(from) {
+		from = nowLocal
+	}
+	if rule.EffectiveUntil != nil && rule.EffectiveUntil.Before(until) {
+		until = *rule.EffectiveUntil
+	}
+	untilLocal := until.In(loc)
+
+	created := 0
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc); !day.After(untilLocal); day = day.AddDate(0, 0, 1) {
+		if !days[day.Weekday()] || exceptions[day.Format("2006-01-02")] {
+			continue
+		}
+
+		startTime := time.Date(day.Year(), day.Month(), day.Day(), startHour, startMinute, 0, 0, loc)
+		endTime := time.Date(day.Year(), day.Month(), day.Day(), endHour, endMinute, 0, 0, loc)
+		if !startTime.After(now) || !endTime.After(startTime) {
+			continue
+		}
+
+		conflicts, err := FindOverlappingSlots(tx, rule.TeacherID, startTime, endTime)
+		if err != nil {
+			return created, err
+		}
+		if len(conflicts) > 0 {
+			continue
+		}
+
+		languageID := rule.LanguageID
+		ruleID := rule.ID
+		slot := models.AvailabilitySlot{
+			TeacherID:          rule.TeacherID,
+			LanguageID:         &languageID,
+			StartTime:          startTime,
+			EndTime:            endTime,
+			MaxStudents:        rule.MaxStudents,
+			AvailabilityRuleID: &ruleID,
+		}
+		inserted, err := createGeneratedSlot(tx, &slot)
+		if err != nil {
+			return created, err
+		}
+		if inserted {
+			created++
+		}
+	}
+
+	return created, nil
+}
+
+// createGeneratedSlot inserts a slot generated from a rule, skipping it if another instance or a
+// manual slot took the time first. Inside a transaction the insert runs in a savepoint, since a
+// failed statement would otherwise abort everything else the transaction has done.
+func createGeneratedSlot(tx *gorm.DB, slot *models.AvailabilitySlot) (bool, error) {
+	_, inTransaction := tx.Statement.ConnPool.(gorm.TxCommitter)
+	if inTransaction {
+		if err := tx.SavePoint("generated_slot").Error; err != nil {
+			return false, err
+		}
+	}
+
+	err := tx.Create(slot).Error
+	if err == nil {
+		return true, nil
+	}
+	if inTransaction {
+		if rollbackErr := tx.RollbackTo("generated_slot").Error; rollbackErr != nil {
+			return false, rollbackErr
+		}
+	}
+	if IsSlotOverlapViolation(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// RemoveUnbookedRuleSlots deletes the future slots generated by a rule that nobody has booked yet.
+func RemoveUnbookedRuleSlots(tx *gorm.DB, rule models.AvailabilityRule) error {
+	return tx.Where("availability_rule_id = ? AND status = ? AND current_students = 0 AND start_time > ?", rule.ID, "available", time.Now()).
+		Delete(&models.AvailabilitySlot{}).Error
+}
@@ -0,0 +1,127 @@
+package bookingstate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	PendingPayment      = "pending_payment"
+	Confirmed           = "confirmed"
+	RescheduleRequested = "reschedule_requested"
+	Completed           = "completed"
+	Unattended          = "unattended"
+	StudentNoShow       = "student_no_show"
+	TeacherNoShow       = "teacher_no_show"
+	Cancelled           = "cancelled"
+)
+
+var transitions = map[string][]string{
+	PendingPayment:      {Confirmed, Cancelled},
+	Confirmed:           {RescheduleRequested, Completed, Unattended, StudentNoShow, TeacherNoShow, Cancelled},
+	RescheduleRequested: {Confirmed, Cancelled},
+	Unattended:          {Completed, StudentNoShow, TeacherNoShow, Cancelled},
+	StudentNoShow:       {},
+	TeacherNoShow:       {},
+	Completed:           {},
+	Cancelled:           {},
+}
+
+type Actor struct {
+	UserID *uuid.UUID
+	Role   string
+}
+
+var System = Actor{Role: "system"}
+
+func UserActor(userID uuid.UUID, role string) Actor {
+	return Actor{UserID: &userID, Role: role}
+}
+
+type UnknownStatusError struct {
+	Status string
+}
+
+func (e *UnknownStatusError) Error() string {
+	return fmt.Sprintf("unknown booking status %q", e.Status)
+}
+
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("a booking cannot move from %q to %q", e.From, e.To)
+}
+
+// ErrStatusChanged means another request moved the booking on between it being loaded and updated.
+var ErrStatusChanged = errors.New("the booking was updated by another request, please reload and try again")
+
+func CanTransition(from, to string) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+func validate(from, to string) error {
+	if _, ok := transitions[from]; !ok {
+		return &UnknownStatusError{Status: from}
+	}
+	if _, ok := transitions[to]; !ok {
+		return &UnknownStatusError{Status: to}
+	}
+	if !CanTransition(from, to) {
+		return &TransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+// Transition moves the booking to a new status, persisting the status column and a history row.
+// Other modified fields on booking are left for the caller to save. The update only applies if the
+// stored status is still the one the booking was loaded with, so of two concurrent transitions only
+// one wins and the other gets ErrStatusChanged.
+func Transition(tx *gorm.DB, booking *models.Booking, to string, actor Actor, reason string) error {
+	from := booking.Status
+	if err := validate(from, to); err != nil {
+		return err
+	}
+
+	result := tx.Model(&models.Booking{}).Where("id = ? AND status = ?", booking.ID, from).Update("status", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStatusChanged
+	}
+	booking.Status = to
+
+	return record(tx, booking.ID, from, to, actor, reason)
+}
+
+// RecordInitial writes the first history row for a newly created booking.
+func RecordInitial(tx *gorm.DB, booking *models.Booking, actor Actor, reason string) error {
+	if _, ok := transitions[booking.Status]; !ok {
+		return &UnknownStatusError{Status: booking.Status}
+	}
+	return record(tx, booking.ID, "", booking.Status, actor, reason)
+}
+
+func record(tx *gorm.DB, bookingID uuid.UUID, from, to string, actor Actor, reason string) error {
+	entry := models.BookingStatusHistory{
+		BookingID: bookingID,
+		ActorID:   actor.UserID,
+		ActorRole: actor.Role,
+		OldStatus: from,
+		NewStatus: to,
+		Reason:    reason,
+	}
+	return tx.Create(&entry).Error
+}
@@ -0,0 +1,41 @@
+package bookingstate
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{PendingPayment, Confirmed, true},
+		{PendingPayment, Cancelled, true},
+		{PendingPayment, Completed, false},
+		{Confirmed, RescheduleRequested, true},
+		{RescheduleRequested, Confirmed, true},
+		{Unattended, Completed, true},
+		{Completed, Cancelled, false},
+		{Cancelled, Confirmed, false},
+		{Completed, Unattended, false},
+		{"unknown", Confirmed, false},
+	}
+	for _, tc := range cases {
+		if got := CanTransition(tc.from, tc.to); got != tc.want {
+			t.Errorf("CanTransition(%q, %q) = %t, want %t", tc.from, tc.to, got, tc.want)
+		}
+	}
+}
+
+func TestValidateRejectsUnknownStatuses(t *testing.T) {
+	if err := validate("unknown", Confirmed); err == nil {
+		t.Fatal("expected an unknown from status to be rejected")
+	}
+	if err := validate(Confirmed, "unknown"); err == nil {
+		t.Fatal("expected an unknown to status to be rejected")
+	}
+	if err := validate(Completed, Confirmed); err == nil {
+		t.Fatal("expected completed to confirmed to be rejected")
+	}
+	if err := validate(Confirmed, Completed); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/notifications"
+)
+
+const (
+	icsLocalTime = "20060102T150405"
+	icsUTCTime   = "20060102T150405Z"
+	icsDomain    = "classlearning.co.ke"
+)
+
+func icsEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// icsLine folds a content line at 75 octets as required by RFC 5545 section 3.1.
+func icsLine(b *strings.Builder, line string) {
+	for len(line) > 75 {
+		cut := 75
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n")
+		line = " " + line[cut:]
+	}
+	b.WriteString(line + "\r\n")
+}
+
+func icsOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	out := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+	if seconds%60 != 0 {
+		out += fmt.Sprintf("%02d", seconds%60)
+	}
+	return out
+}
+
+// zoneTransitions finds the instants in [from, to) where loc changes its UTC offset.
+func zoneTransitions(loc *time.Location, from, to time.Time) []time.Time {
+	var transitions []time.Time
+	for day := from; day.Before(to); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+		_, before := day.In(loc).Zone()
+		_, after := next.In(loc).Zone()
+		if before == after {
+			continue
+		}
+		lo, hi := day, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if _, offset := mid.In(loc).Zone(); offset == before {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		transitions = append(transitions, hi)
+	}
+	return transitions
+}
+
+func writeTimeZone(b *strings.Builder, loc *time.Location, earliest, latest time.Time) {
+	from := time.Date(earliest.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(latest.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	name, offset := from.In(loc).Zone()
+	icsLine(b, "BEGIN:VTIMEZONE")
+	icsLine(b, "TZID:"+loc.String())
+	icsLine(b, "BEGIN:STANDARD")
+	icsLine(b, "DTSTART:19700101T000000")
+	icsLine(b, "TZOFFSETFROM:"+icsOffset(offset))
+	icsLine(b, "TZOFFSETTO:"+icsOffset(offset))
+	icsLine(b, "TZNAME:"+name)
+	icsLine(b, "END:STANDARD")
+
+	for _, at := range zoneTransitions(loc, from, to) {
+		_, before := at.Add(-time.Second).In(loc).Zone()
+		local := at.In(loc)
+		name, after := local.Zone()
+		kind := "STANDARD"
+		if local.IsDST() {
+			kind = "DAYLIGHT"
+		}
+		icsLine(b, "BEGIN:"+kind)
+		icsLine(b, "DTSTART:"+at.In(time.FixedZone("", before)).Format(icsLocalTime))
+		icsLine(b, "TZOFFSETFROM:"+icsOffset(before))
+		icsLine(b, "TZOFFSETTO:"+icsOffset(after))
+		icsLine(b, "TZNAME:"+name)
+		icsLine(b, "END:"+kind)
+	}
+	icsLine(b, "END:VTIMEZONE")
+}
+
+func writeEventTime(b *strings.Builder, property string, t time.Time, loc *time.Location) {
+	if loc == time.UTC {
+		icsLine(b, property+":"+t.UTC().Format(icsUTCTime))
+		return
+	}
+	icsLine(b, property+";TZID="+loc.String()+":"+t.In(loc).Format(icsLocalTime))
+}
+
+// BuildCalendar renders bookings as an RFC 5545 calendar, with times expressed in the viewer's time zone.
+// Bookings must have Student, Teacher and AvailabilitySlot.Language loaded.
+func BuildCalendar(name, method string, bookings []models.Booking, viewer models.User) []byte {
+	loc := UserLocation(viewer)
+
+	var b strings.Builder
+	icsLine(&b, "BEGIN:VCALENDAR")
+	icsLine(&b, "VERSION:2.0")
+	icsLine(&b, "PRODID:-//Class Learning//Language Tutor//EN")
+	icsLine(&b, "CALSCALE:GREGORIAN")
+	if method != "" {
+		icsLine(&b, "METHOD:"+method)
+	}
+	icsLine(&b, "X-WR-CALNAME:"+icsEscape(name))
+	if loc != time.UTC {
+		icsLine(&b, "X-WR-TIMEZONE:"+loc.String())
+	}
+
+	if loc != time.UTC && len(bookings) > 0 {
+		earliest, latest := bookings[0].AvailabilitySlot.StartTime, bookings[0].AvailabilitySlot.EndTime
+		for _, booking := range bookings {
+			if booking.AvailabilitySlot.StartTime.Before(earliest) {
+				earliest = booking.AvailabilitySlot.StartTime
+			}
+			if booking.AvailabilitySlot.EndTime.After(latest) {
+				latest = booking.AvailabilitySlot.EndTime
+			}
+		}
+		writeTimeZone(&b, loc, earliest, latest)
+	}
+
+	for _, booking := range bookings {
+		otherParty := booking.Teacher.FullName
+		if viewer.ID == booking.TeacherID {
+			otherParty = booking.Student.FullName
+		}
+
+		icsLine(&b, "BEGIN:VEVENT")
+		icsLine(&b, fmt.Sprintf("UID:%s@%s", booking.ID, icsDomain))
+		icsLine(&b, "DTSTAMP:"+booking.UpdatedAt.UTC().Format(icsUTCTime))
+		writeEventTime(&b, "DTSTART", booking.AvailabilitySlot.StartTime, loc)
+		writeEventTime(&b, "DTEND", booking.AvailabilitySlot.EndTime, loc)
+		icsLine(&b, "SUMMARY:"+icsEscape(fmt.Sprintf("%s class with %s", booking.AvailabilitySlot.Language.Name, otherParty)))
+		if booking.MeetingLink != nil && *booking.MeetingLink != "" {
+			icsLine(&b, "LOCATION:"+icsEscape(*booking.MeetingLink))
+			icsLine(&b, "URL:"+*booking.MeetingLink)
+			icsLine(&b, "DESCRIPTION:"+icsEscape("Join the class: "+*booking.MeetingLink))
+		} else {
+			icsLine(&b, "DESCRIPTION:"+icsEscape("The meeting link will be shared before the class starts."))
+		}
+		icsLine(&b, "STATUS:CONFIRMED")
+		icsLine(&b, "END:VEVENT")
+	}
+
+	icsLine(&b, "END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// BookingInvite builds the .ics attachment sent with booking confirmation emails.
+func BookingInvite(booking models.Booking, viewer models.User) notifications.Attachment {
+	return notifications.Attachment{
+		Name:    "class.ics",
+		Content: BuildCalendar("Class Learning", "PUBLISH", []models.Booking{booking}, viewer),
+	}
+}
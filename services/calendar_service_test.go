@@ -0,0 +1,46 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestICSLineFoldsAt75Octets(t *testing.T) {
+	line := "DESCRIPTION:" + strings.Repeat("Lección de español con café ☕ ", 8)
+
+	var b strings.Builder
+	icsLine(&b, line)
+	out := b.String()
+
+	if !strings.HasSuffix(out, "\r\n") {
+		t.Fatalf("folded line does not end with CRLF: %q", out)
+	}
+	physical := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+	if len(physical) < 2 {
+		t.Fatalf("expected the line to be folded, got %q", out)
+	}
+	for i, part := range physical {
+		if len(part) > 75 {
+			t.Errorf("line %d is %d octets: %q", i, len(part), part)
+		}
+		if i > 0 && !strings.HasPrefix(part, " ") {
+			t.Errorf("continuation line %d does not start with a space: %q", i, part)
+		}
+		if !utf8.ValidString(part) {
+			t.Errorf("line %d splits a multi-byte character: %q", i, part)
+		}
+	}
+
+	if unfolded := strings.ReplaceAll(strings.TrimSuffix(out, "\r\n"), "\r\n ", ""); unfolded != line {
+		t.Fatalf("unfolding did not restore the line:\n got %q\nwant %q", unfolded, line)
+	}
+}
+
+func TestICSLineLeavesShortLinesAlone(t *testing.T) {
+	var b strings.Builder
+	icsLine(&b, "SUMMARY:French class")
+	if got := b.String(); got != "SUMMARY:French class\r\n" {
+		t.Fatalf("got %q", got)
+	}
+}
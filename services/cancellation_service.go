@@ -0,0 +1,191 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	config "github.com/anjiri1684/language_tutor/configs"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/services/bookingstate"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultCancellationPolicy = "24:100,2:50"
+
+type CancellationTier struct {
+	MinHoursBefore float64 `json:"min_hours_before"`
+	RefundPercent  float64 `json:"refund_percent"`
+}
+
+type CancellationResult struct {
+	RefundPercent float64 `json:"refund_percent"`
+	RefundAmount  float64 `json:"refund_amount"`
+	Currency      string  `json:"currency"`
+	RefundedTo    string  `json:"refunded_to"`
+	RefundMinutes int     `json:"refund_minutes,omitempty"`
+	// NothingCharged is set when the booking had not been paid for, so there was nothing to refund.
+	NothingCharged bool `json:"nothing_charged,omitempty"`
+}
+
+// CancellationPolicy reads CANCELLATION_POLICY as "hours:percent" pairs, e.g. "24:100,2:50"
+// means a full refund 24h or more before the class, half between 2h and 24h, and nothing after.
+func CancellationPolicy() []CancellationTier {
+	raw := config.Config("CANCELLATION_POLICY")
+	if raw == "" {
+		raw = defaultCancellationPolicy
+	}
+
+	var tiers []CancellationTier
+	for _, part := range strings.Split(raw, ",") {
+		pieces := strings.Split(strings.TrimSpace(part), ":")
+		if len(pieces) != 2 {
+			continue
+		}
+		hours, err1 := strconv.ParseFloat(pieces[0], 64)
+		percent, err2 := strconv.ParseFloat(pieces[1], 64)
+		if err1 != nil || err2 != nil || percent < 0 || percent > 100 {
+			continue
+		}
+		tiers = append(tiers, CancellationTier{MinHoursBefore: hours, RefundPercent: percent})
+	}
+
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinHoursBefore > tiers[j].MinHoursBefore })
+	return tiers
+}
+
+func RefundPercentFor(startTime, now time.Time) float64 {
+	hoursBefore := startTime.Sub(now).Hours()
+	for _, tier := range CancellationPolicy() {
+		if hoursBefore >= tier.MinHoursBefore {
+			return tier.RefundPercent
+		}
+	}
+	return 0
+}
+
+// BundleStatusFor is "exhausted" once a bundle can no longer cover a standard session of its
+// language, and "active" otherwise. Bundle.Language must be loaded.
+func BundleStatusFor(studentBundle models.StudentBundle) string {
+	sessionMinutes := studentBundle.Bundle.Language.SessionMinutes
+	if sessionMinutes <= 0 {
+		sessionMinutes = 60
+	}
+	if studentBundle.RemainingMinutes < sessionMinutes {
+		return "exhausted"
+	}
+	return "active"
+}
+
+func ReturnBundleMinutes(tx *gorm.DB, studentBundleID uuid.UUID, minutes int) error {
+	var studentBundle models.StudentBundle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&studentBundle, "id = ?", studentBundleID).Error; err != nil {
+		return err
+	}
+	if err := tx.Preload("Language").First(&studentBundle.Bundle, "id = ?", studentBundle.BundleID).Error; err != nil {
+		return err
+	}
+	studentBundle.RemainingMinutes += minutes
+	if studentBundle.Status == "exhausted" || studentBundle.Status == "active" {
+		studentBundle.Status = BundleStatusFor(studentBundle)
+	}
+	return tx.Save(&studentBundle).Error
+}
+
+func AddCredit(tx *gorm.DB, userID uuid.UUID, amount float64) error {
+	return tx.Model(&models.User{}).Where("id = ?", userID).Update("credit_balance", gorm.Expr("credit_balance + ?", amount)).Error
+}
+
+// CancelBooking cancels the booking, frees its seat and refunds refundPercent of what was paid.
+// Credit and bundle payments are always returned in kind; M-Pesa and PayPal payments go to the
+// student's credit balance when refundTo is "credit", otherwise they are queued as a refund request.
+func CancelBooking(tx *gorm.DB, booking *models.Booking, actor bookingstate.Actor, reason string, refundPercent float64, refundTo string) (CancellationResult, error) {
+	result := CancellationResult{RefundPercent: refundPercent, Currency: booking.Currency}
+
+	if err := bookingstate.Transition(tx, booking, bookingstate.Cancelled, actor, reason); err != nil {
+		return result, err
+	}
+	if err := ReleaseSeat(tx, booking.AvailabilitySlotID); err != nil {
+		return result, err
+	}
+	return RefundBooking(tx, booking, reason, refundPercent, refundTo)
+}
+
+// RefundBooking returns refundPercent of what was paid for a booking without changing its status.
+func RefundBooking(tx *gorm.DB, booking *models.Booking, reason string, refundPercent float64, refundTo string) (CancellationResult, error) {
+	result := CancellationResult{RefundPercent: refundPercent, Currency: booking.Currency}
+
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, "booking_id = ?", booking.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result.NothingCharged = true
+			return result, nil
+		}
+		return result, err
+	}
+
+	if payment.Status == "pending" {
+		result.NothingCharged = true
+		payment.Status = "cancelled"
+		return result, tx.Save(&payment).Error
+	}
+	if payment.Status != "succeeded" || refundPercent <= 0 {
+		return result, nil
+	}
+
+	fullRefund := refundPercent >= 100
+	switch {
+	case payment.Provider == "bundle":
+		if booking.StudentBundleID == nil {
+			return result, nil
+		}
+		// Bundles are minutes, so a partial refund tier returns that share of the class's minutes.
+		result.RefundMinutes = int(math.Round(float64(booking.DurationMinutes) * refundPercent / 100))
+		if result.RefundMinutes == 0 {
+			return result, nil
+		}
+		if err := ReturnBundleMinutes(tx, *booking.StudentBundleID, result.RefundMinutes); err != nil {
+			return result, err
+		}
+		result.RefundedTo = "bundle"
+
+	case payment.Provider == "credit" || refundTo == "credit":
+		result.RefundAmount = math.Round(booking.Price*refundPercent) / 100
+		if err := AddCredit(tx, booking.StudentID, result.RefundAmount); err != nil {
+			return result, err
+		}
+		paid, _ := paidAmount(payment)
+		if _, err := recordCreditRefund(tx, &payment, paid*refundPercent/100, result.RefundAmount, reason, nil); err != nil {
+			return result, err
+		}
+		payment.RefundReason = &reason
+		result.RefundedTo = "credit"
+		return result, applyRefundsToPayment(tx, &payment)
+
+	default:
+		result.RefundAmount = math.Round(payment.Amount*refundPercent) / 100
+		result.Currency = payment.Currency
+		result.RefundedTo = payment.Provider
+		requested := "requested"
+		payment.RefundStatus = &requested
+		payment.RefundReason = &reason
+		payment.RefundAmount = &result.RefundAmount
+		return result, tx.Save(&payment).Error
+	}
+
+	approved := "approved"
+	payment.RefundStatus = &approved
+	payment.RefundReason = &reason
+	payment.RefundAmount = &result.RefundAmount
+	if fullRefund {
+		payment.Status = "refunded"
+	} else {
+		payment.Status = "partially_refunded"
+	}
+	return result, tx.Save(&payment).Error
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCancellationPolicyParsesAndSortsTiers(t *testing.T) {
+	t.Setenv("CANCELLATION_POLICY", "2:50, 48:100,bad,24:75,6:150,12:x")
+
+	tiers := CancellationPolicy()
+	want := []CancellationTier{{48, 100}, {24, 75}, {2, 50}}
+	if len(tiers) != len(want) {
+		t.Fatalf("got %d tiers %+v, want %+v", len(tiers), tiers, want)
+	}
+	for i := range want {
+		if tiers[i] != want[i] {
+			t.Fatalf("tier %d = %+v, want %+v", i, tiers[i], want[i])
+		}
+	}
+}
+
+func TestCancellationPolicyFallsBackToDefault(t *testing.T) {
+	t.Setenv("CANCELLATION_POLICY", "")
+
+	tiers := CancellationPolicy()
+	if len(tiers) != 2 || tiers[0] != (CancellationTier{24, 100}) || tiers[1] != (CancellationTier{2, 50}) {
+		t.Fatalf("got %+v, want the default 24:100,2:50", tiers)
+	}
+}
+
+func TestRefundPercentFor(t *testing.T) {
+	t.Setenv("CANCELLATION_POLICY", "24:100,2:50")
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		before time.Duration
+		want   float64
+	}{
+		{48 * time.Hour, 100},
+		{24 * time.Hour, 100},
+		{23 * time.Hour, 50},
+		{2 * time.Hour, 50},
+		{time.Hour, 0},
+	}
+	for _, tc := range cases {
+		if got := RefundPercentFor(now.Add(tc.before), now); got != tc.want {
+			t.Errorf("%s before the class: got %.0f%%, want %.0f%%", tc.before, got, tc.want)
+		}
+	}
+}
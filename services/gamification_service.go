@@ -5,6 +5,7 @@ import (
 
 	"github.com/anjiri1684/language_tutor/database"
 	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/websocket"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -15,6 +16,7 @@ const (
 )
 
 func AwardRewardsForClassCompletion(studentID uuid.UUID) {
+	var awarded []models.Badge
 	err := database.DB.Transaction(func(tx *gorm.DB) error {
 		var student models.User
 		if err := tx.Preload("Badges").First(&student, "id = ?", studentID).Error; err != nil {
@@ -41,6 +43,7 @@ func AwardRewardsForClassCompletion(studentID uuid.UUID) {
 				if err := tx.Model(&student).Association("Badges").Append(&firstClassBadge); err != nil {
 					return err
 				}
+				awarded = append(awarded, firstClassBadge)
 			} else {
 				log.Printf("Warning: Badge '%s' not found in database. Cannot award.", badgeNameFirstClass)
 			}
@@ -53,5 +56,8 @@ func AwardRewardsForClassCompletion(studentID uuid.UUID) {
 		log.Printf("🔥 Failed to award rewards to student %s: %v", studentID, err)
 	} else {
 		log.Printf("✅ Awarded %d XP to student %s.", xpForClassCompletion, studentID)
+		for _, badge := range awarded {
+			websocket.Publish(websocket.EventBadgeAwarded, badge, studentID)
+		}
 	}
 }
\ No newline at end of file
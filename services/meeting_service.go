@@ -0,0 +1,85 @@
+package services
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/meetings"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/websocket"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProvisionMeetingLink gives the booking its slot's room, creating the room with the configured provider
+// the first time a student is confirmed into the slot so everyone in a group class shares it.
+// If that provider fails the built-in Jitsi provider is used so the class always has a link.
+// The booking must have its AvailabilitySlot loaded.
+func ProvisionMeetingLink(db *gorm.DB, booking *models.Booking) error {
+	var slot models.AvailabilitySlot
+	if err := db.First(&slot, "id = ?", booking.AvailabilitySlotID).Error; err != nil {
+		return err
+	}
+
+	if slot.MeetingLink == nil || *slot.MeetingLink == "" {
+		// The provider is called without holding the slot row, so a slow provider cannot block seat
+		// changes. Two confirmations may both create a room; the first one stored is kept.
+		link, err := createSlotMeeting(slot, booking.AvailabilitySlot.Language.Name)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&models.AvailabilitySlot{}).
+			Where("id = ? AND (meeting_link IS NULL OR meeting_link = '')", slot.ID).
+			Update("meeting_link", link).Error; err != nil {
+			return err
+		}
+		if err := db.First(&slot, "id = ?", slot.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	booking.MeetingLink = slot.MeetingLink
+	booking.AvailabilitySlot.MeetingLink = slot.MeetingLink
+	return db.Model(&models.Booking{}).Where("id = ?", booking.ID).Update("meeting_link", *slot.MeetingLink).Error
+}
+
+func createSlotMeeting(slot models.AvailabilitySlot, languageName string) (string, error) {
+	topic := "Class"
+	if languageName != "" {
+		topic = languageName + " class"
+	}
+	meeting := meetings.Meeting{
+		Reference: fmt.Sprintf("Slot %s", slot.ID),
+		Topic:     topic,
+		StartTime: slot.StartTime,
+		EndTime:   slot.EndTime,
+	}
+
+	provider := meetings.Current()
+	link, err := provider.CreateMeeting(meeting)
+	if err != nil {
+		log.Printf("Meeting provider %s failed for slot %s, falling back to %s: %v", provider.Name(), slot.ID, meetings.Default().Name(), err)
+		return meetings.Default().CreateMeeting(meeting)
+	}
+	return link, nil
+}
+
+// RegenerateMeetingLink moves a rescheduled booking into its new slot's room, creating the room if
+// nobody else is booked into that slot yet. The old slot keeps its room for any remaining students.
+func RegenerateMeetingLink(bookingID uuid.UUID) {
+	var booking models.Booking
+	if err := database.DB.Preload("AvailabilitySlot.Language").First(&booking, "id = ?", bookingID).Error; err != nil {
+		log.Printf("Could not load booking %s to regenerate its meeting link: %v", bookingID, err)
+		return
+	}
+	if err := ProvisionMeetingLink(database.DB, &booking); err != nil {
+		log.Printf("Could not regenerate meeting link for booking %s: %v", bookingID, err)
+		return
+	}
+
+	websocket.Publish(websocket.EventMeetingLinkAdded, map[string]interface{}{
+		"booking_id":   booking.ID,
+		"meeting_link": booking.MeetingLink,
+	}, booking.StudentID, booking.TeacherID)
+}
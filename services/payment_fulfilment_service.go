@@ -0,0 +1,315 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/notifications"
+	"github.com/anjiri1684/language_tutor/payments"
+	"github.com/anjiri1684/language_tutor/services/bookingstate"
+	"github.com/anjiri1684/language_tutor/websocket"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChargeAmount converts a price into the currency the gateway settles in.
+// Only USD to KES conversion is supported, which is all M-Pesa needs. KES amounts are always
+// whole shillings since M-Pesa cannot charge fractions, so the stored amount matches what is sent.
+func ChargeAmount(gateway payments.Gateway, price float64, currency string) (float64, string, error) {
+	settlement := gateway.SettlementCurrency()
+	if settlement == "" || settlement == currency {
+		if currency == "KES" {
+			price = math.Round(price)
+		}
+		return price, currency, nil
+	}
+	if settlement != "KES" || currency != "USD" {
+		return 0, "", fmt.Errorf("cannot convert %s to %s for %s", currency, settlement, gateway.Name())
+	}
+
+	kesPrice, err := ConvertUSDToKES(price)
+	if err != nil {
+		return 0, "", err
+	}
+	return math.Round(kesPrice), "KES", nil
+}
+
+// FindPaymentForWebhook looks a payment up by each reference the provider sent back in turn,
+// so a garbled invoice number still matches on the checkout or merchant request id.
+func FindPaymentForWebhook(provider string, event *payments.WebhookEvent) (models.Payment, error) {
+	var payment models.Payment
+	lookups := []struct{ column, value string }{
+		{"id", event.PaymentID},
+		{"provider_order_id", event.ProviderOrderID},
+		{"merchant_request_id", event.MerchantRequestID},
+	}
+	for _, lookup := range lookups {
+		if lookup.value == "" {
+			continue
+		}
+		if _, err := uuid.Parse(lookup.value); lookup.column == "id" && err != nil {
+			continue
+		}
+		err := database.DB.Where("provider = ? AND "+lookup.column+" = ?", provider, lookup.value).First(&payment).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return payment, err
+		}
+	}
+	return payment, gorm.ErrRecordNotFound
+}
+
+const (
+	PaymentFulfilled        = "fulfilled"
+	PaymentAlreadyFulfilled = "already_fulfilled"
+	// PaymentLate means the money arrived after the reservation expired or was cancelled; it is kept and flagged for refund.
+	PaymentLate = "late"
+	// PaymentNeedsReview means the provider reported a different amount or currency than was charged.
+	PaymentNeedsReview = "needs_review"
+)
+
+var ErrPaymentNotPayable = errors.New("this payment is no longer awaiting payment")
+var ErrPaymentNotUnderReview = errors.New("this payment is not awaiting review")
+
+// ProviderPayment is what a provider reported about a successful payment. Amount and Currency
+// are left empty when the provider does not say how much was paid.
+type ProviderPayment struct {
+	TxnID    string
+	Amount   float64
+	Currency string
+}
+
+// paymentMismatch describes how the reported payment differs from what was charged, or returns "".
+func paymentMismatch(payment models.Payment, paid ProviderPayment) string {
+	if paid.Currency != "" && payment.Currency != "" && !strings.EqualFold(paid.Currency, payment.Currency) {
+		return fmt.Sprintf("wrong currency: paid %.2f %s, expected %.2f %s", paid.Amount, paid.Currency, payment.Amount, payment.Currency)
+	}
+	if paid.Amount == 0 && paid.Currency == "" {
+		return ""
+	}
+	paidCents := math.Round(paid.Amount * 100)
+	expectedCents := math.Round(payment.Amount * 100)
+	switch {
+	case paidCents < expectedCents:
+		return fmt.Sprintf("underpayment: paid %.2f, expected %.2f %s", paid.Amount, payment.Amount, payment.Currency)
+	case paidCents > expectedCents:
+		return fmt.Sprintf("overpayment: paid %.2f, expected %.2f %s", paid.Amount, payment.Amount, payment.Currency)
+	}
+	return ""
+}
+
+// CompletePayment is the single place a provider payment is marked successful. It checks the
+// reported amount against what was charged, confirms the booking or activates the bundle the
+// payment was for, then notifies the student and teacher and completes any pending referral.
+// Mismatched payments are held as needs_review instead. Repeated calls for the same payment are harmless.
+func CompletePayment(paymentID uuid.UUID, paid ProviderPayment, note string) (string, error) {
+	return completePayment(paymentID, paid, note, false)
+}
+
+// AcceptReviewedPayment fulfils a payment held for review as if the amount had matched.
+func AcceptReviewedPayment(paymentID uuid.UUID, note string) (string, error) {
+	return completePayment(paymentID, ProviderPayment{}, note, true)
+}
+
+func completePayment(paymentID uuid.UUID, paid ProviderPayment, note string, reviewed bool) (string, error) {
+	var payment models.Payment
+	outcome := PaymentFulfilled
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, "id = ?", paymentID).Error; err != nil {
+			return err
+		}
+		if paid.TxnID != "" {
+			payment.ProviderTxnID = &paid.TxnID
+		}
+
+		flagLate := func() error {
+			log.Printf("🔥 Payment %s succeeded after its reservation was released; flagging for refund", payment.ID)
+			outcome = PaymentLate
+			refundStatus := "requested"
+			refundReason := "Payment received after the reservation was released"
+			payment.Status = "succeeded"
+			payment.RefundStatus = &refundStatus
+			payment.RefundReason = &refundReason
+			if payment.PaidAmount != nil {
+				payment.RefundAmount = payment.PaidAmount
+			}
+			return tx.Save(&payment).Error
+		}
+
+		switch payment.Status {
+		case "succeeded":
+			outcome = PaymentAlreadyFulfilled
+			return nil
+		case "needs_review":
+			if !reviewed {
+				outcome = PaymentNeedsReview
+				return nil
+			}
+		case "pending", "failed", "expired", "cancelled":
+			if reviewed {
+				return ErrPaymentNotUnderReview
+			}
+		default:
+			return ErrPaymentNotPayable
+		}
+
+		if !reviewed && (paid.Amount != 0 || paid.Currency != "") {
+			payment.PaidAmount = &paid.Amount
+			if paid.Currency != "" {
+				currency := strings.ToUpper(paid.Currency)
+				payment.PaidCurrency = &currency
+			}
+			if reason := paymentMismatch(payment, paid); reason != "" {
+				log.Printf("🔥 Payment %s held for review: %s", payment.ID, reason)
+				outcome = PaymentNeedsReview
+				payment.Status = "needs_review"
+				payment.ReviewReason = &reason
+				return tx.Save(&payment).Error
+			}
+		}
+
+		// The hold expired or the student cancelled before paying; keep the money for a refund.
+		if payment.Status == "expired" || payment.Status == "cancelled" {
+			return flagLate()
+		}
+
+		var booking models.Booking
+		if payment.BookingID != nil {
+			if err := tx.First(&booking, "id = ?", payment.BookingID).Error; err != nil { return err }
+			// A failed payment's booking may already have been released by the expiry job.
+			if booking.Status != bookingstate.PendingPayment {
+				return flagLate()
+			}
+		}
+
+		var studentBundle models.StudentBundle
+		if payment.StudentBundleID != nil {
+			if err := tx.First(&studentBundle, "id = ?", payment.StudentBundleID).Error; err != nil { return err }
+			if studentBundle.Status != "pending_payment" {
+				return flagLate()
+			}
+		}
+
+		payment.Status = "succeeded"
+		if err := tx.Save(&payment).Error; err != nil { return err }
+
+		if payment.BookingID != nil {
+			if err := bookingstate.Transition(tx, &booking, bookingstate.Confirmed, bookingstate.System, note); err != nil { return err }
+		}
+		if payment.StudentBundleID != nil {
+			if err := tx.Model(&studentBundle).Update("status", "active").Error; err != nil { return err }
+		}
+		return nil
+	})
+	if err != nil || outcome != PaymentFulfilled {
+		return outcome, err
+	}
+
+	if payment.BookingID != nil {
+		go func() {
+			SendBookingConfirmation(*payment.BookingID,
+				"<h1>Booking Confirmed</h1><p>Your payment was successful and your class is confirmed.</p>",
+				"<h1>New Booking</h1><p>A student has booked and paid for a session with you. Please prepare for the class.</p>")
+
+			var booking models.Booking
+			if err := database.DB.First(&booking, "id = ?", payment.BookingID).Error; err == nil {
+				CompleteReferralIfApplicable(booking.StudentID)
+			}
+		}()
+	}
+	if payment.StudentBundleID != nil {
+		go func() {
+			var studentBundle models.StudentBundle
+			if err := database.DB.Preload("Student").First(&studentBundle, "id = ?", payment.StudentBundleID).Error; err != nil {
+				log.Printf("Could not load student bundle %s for confirmation: %v", *payment.StudentBundleID, err)
+				return
+			}
+			notifications.SendEmail(studentBundle.Student.FullName, studentBundle.Student.Email, "Bundle Purchase Confirmed!", "<h1>Success!</h1><p>Your class bundle purchase is complete. You can now use your bundle minutes to book sessions.</p>")
+			CompleteReferralIfApplicable(studentBundle.StudentID)
+		}()
+	}
+
+	return outcome, nil
+}
+
+// RejectReviewedPayment turns down a payment held for review: the booking or bundle it was for is
+// cancelled and whatever the student actually paid is queued as a refund request.
+func RejectReviewedPayment(paymentID uuid.UUID, actor bookingstate.Actor, reason string) (models.Payment, error) {
+	var payment models.Payment
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, "id = ?", paymentID).Error; err != nil {
+			return err
+		}
+		if payment.Status != "needs_review" {
+			return ErrPaymentNotUnderReview
+		}
+
+		if payment.BookingID != nil {
+			var booking models.Booking
+			if err := tx.First(&booking, "id = ?", payment.BookingID).Error; err != nil { return err }
+			if booking.Status == bookingstate.PendingPayment {
+				if err := bookingstate.Transition(tx, &booking, bookingstate.Cancelled, actor, reason); err != nil { return err }
+				if err := ReleaseSeat(tx, booking.AvailabilitySlotID); err != nil { return err }
+			}
+		}
+		if payment.StudentBundleID != nil {
+			if err := tx.Model(&models.StudentBundle{}).
+				Where("id = ? AND status = ?", payment.StudentBundleID, "pending_payment").
+				Update("status", "cancelled").Error; err != nil { return err }
+		}
+
+		requested := "requested"
+		payment.Status = "succeeded"
+		payment.RefundStatus = &requested
+		payment.RefundReason = &reason
+		payment.RefundAmount = payment.PaidAmount
+		return tx.Save(&payment).Error
+	})
+	return payment, err
+}
+
+// FailPayment records a declined or cancelled provider payment. The booking keeps its seat until
+// the unpaid reservation expires, so the student can retry.
+func FailPayment(paymentID uuid.UUID) error {
+	return database.DB.Model(&models.Payment{}).
+		Where("id = ? AND status = ?", paymentID, "pending").
+		Update("status", "failed").Error
+}
+
+// SendBookingConfirmation provisions the class's meeting room if it has none yet,
+// then emails both parties with the join link and a calendar invite in their own time zone.
+func SendBookingConfirmation(bookingID uuid.UUID, studentHTML, teacherHTML string) {
+	var booking models.Booking
+	if err := database.DB.Preload("Student").Preload("Teacher").Preload("AvailabilitySlot.Language").First(&booking, "id = ?", bookingID).Error; err != nil {
+		log.Printf("Could not load booking %s for confirmation email: %v", bookingID, err)
+		return
+	}
+	if booking.MeetingLink == nil || *booking.MeetingLink == "" {
+		if err := ProvisionMeetingLink(database.DB, &booking); err != nil {
+			log.Printf("Could not provision meeting link for booking %s: %v", bookingID, err)
+		}
+	}
+
+	classTime := fmt.Sprintf("<p><b>Class time:</b> %s</p>", ClassTimeForBoth(booking.AvailabilitySlot.StartTime, booking.Student, booking.Teacher))
+	if booking.MeetingLink != nil {
+		classTime += fmt.Sprintf("<p><b>Meeting Link:</b> <a href='%s'>Join Class</a></p>", *booking.MeetingLink)
+	}
+	notifications.SendEmailWithAttachments(booking.Student.FullName, booking.Student.Email, "Your Booking is Confirmed!",
+		studentHTML+classTime, BookingInvite(booking, booking.Student))
+	notifications.SendEmailWithAttachments(booking.Teacher.FullName, booking.Teacher.Email, "You Have a New Booking!",
+		teacherHTML+classTime, BookingInvite(booking, booking.Teacher))
+
+	websocket.Publish(websocket.EventBookingConfirmed, map[string]interface{}{
+		"booking_id":           booking.ID,
+		"availability_slot_id": booking.AvailabilitySlotID,
+		"start_time":           booking.AvailabilitySlot.StartTime,
+		"end_time":             booking.AvailabilitySlot.EndTime,
+		"meeting_link":         booking.MeetingLink,
+	}, booking.StudentID, booking.TeacherID)
+}
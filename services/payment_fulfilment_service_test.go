@@ -0,0 +1,248 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/payments"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestChargeAmountKeepsPriceInSettlementCurrency(t *testing.T) {
+	gateway := payments.NewFakeGateway("fake", "")
+	price, currency, err := ChargeAmount(gateway, 12.5, "EUR")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if price != 12.5 || currency != "EUR" {
+		t.Fatalf("got %.2f %s, want 12.50 EUR", price, currency)
+	}
+}
+
+func TestChargeAmountRoundsKESToWholeShillings(t *testing.T) {
+	gateway := payments.NewFakeGateway("fake-kes", "KES")
+	price, currency, err := ChargeAmount(gateway, 1499.6, "KES")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if price != 1500 || currency != "KES" {
+		t.Fatalf("got %.2f %s, want 1500 KES", price, currency)
+	}
+}
+
+func TestChargeAmountOnlyConvertsFromUSD(t *testing.T) {
+	gateway := payments.NewFakeGateway("fake-kes", "KES")
+	if _, _, err := ChargeAmount(gateway, 20, "EUR"); err == nil {
+		t.Fatal("expected converting EUR to KES to fail")
+	}
+}
+
+func TestPaymentMismatch(t *testing.T) {
+	payment := models.Payment{Amount: 1500, Currency: "KES"}
+	cases := []struct {
+		name string
+		paid ProviderPayment
+		want string
+	}{
+		{"exact", ProviderPayment{Amount: 1500, Currency: "KES"}, ""},
+		{"currency case", ProviderPayment{Amount: 1500, Currency: "kes"}, ""},
+		{"not reported", ProviderPayment{}, ""},
+		{"under", ProviderPayment{Amount: 1499, Currency: "KES"}, "underpayment"},
+		{"over", ProviderPayment{Amount: 1500.01, Currency: "KES"}, "overpayment"},
+		{"currency", ProviderPayment{Amount: 1500, Currency: "USD"}, "wrong currency"},
+	}
+	for _, tc := range cases {
+		got := paymentMismatch(payment, tc.paid)
+		if tc.want == "" && got != "" {
+			t.Errorf("%s: unexpected mismatch %q", tc.name, got)
+		}
+		if tc.want != "" && !strings.HasPrefix(got, tc.want) {
+			t.Errorf("%s: got %q, want a %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+// setupPaymentDB points database.DB at TEST_DATABASE_URL, a throwaway Postgres database.
+func setupPaymentDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		DisableForeignKeyConstraintWhenMigrating: true,
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	err = db.AutoMigrate(&models.User{}, &models.Language{}, &models.Bundle{}, &models.StudentBundle{},
+		&models.Booking{}, &models.BookingStatusHistory{}, &models.Payment{}, &models.Refund{}, &models.Referral{})
+	if err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	database.DB = db
+}
+
+// create inserts value and deletes it again when the test finishes.
+func create(t *testing.T, value interface{}) {
+	t.Helper()
+	if err := database.DB.Create(value).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.DB.Delete(value) })
+}
+
+// pendingBundlePayment creates a bundle purchase awaiting payment through gateway and returns the
+// payment with the fake order it was initiated as.
+func pendingBundlePayment(t *testing.T, gateway *payments.FakeGateway, amount float64) (models.Payment, string) {
+	t.Helper()
+	suffix := uuid.NewString()[:8]
+
+	student := models.User{FullName: "Test Student", Email: fmt.Sprintf("student-%s@example.com", suffix), Password: "x"}
+	language := models.Language{Name: "Language " + suffix, PricePerSession: amount, Currency: "USD"}
+	create(t, &student)
+	create(t, &language)
+
+	bundle := models.Bundle{Name: "Bundle " + suffix, LanguageID: language.ID, TotalMinutes: 300, Price: amount, Currency: "USD"}
+	create(t, &bundle)
+	studentBundle := models.StudentBundle{StudentID: student.ID, BundleID: bundle.ID, PurchaseDate: time.Now(), RemainingMinutes: 300, Status: "pending_payment"}
+	create(t, &studentBundle)
+
+	payment := models.Payment{StudentBundleID: &studentBundle.ID, Amount: amount, Currency: "USD", Provider: gateway.Name(), Status: "pending"}
+	create(t, &payment)
+
+	result, err := gateway.Initiate(payments.InitiateRequest{PaymentID: payment.ID.String(), Amount: amount, Currency: "USD"})
+	if err != nil { t.Fatal(err) }
+	payment.ProviderOrderID = &result.ProviderOrderID
+	if err := database.DB.Save(&payment).Error; err != nil { t.Fatal(err) }
+	return payment, result.ProviderOrderID
+}
+
+// payViaWebhook simulates the provider reporting a successful payment and runs fulfilment on it.
+func payViaWebhook(t *testing.T, gateway *payments.FakeGateway, body string) (string, error) {
+	t.Helper()
+	event, err := gateway.VerifyWebhook(payments.WebhookRequest{Body: []byte(body)})
+	if err != nil { t.Fatal(err) }
+	paymentID, err := uuid.Parse(event.PaymentID)
+	if err != nil { t.Fatal(err) }
+	paid := ProviderPayment{TxnID: event.ProviderTxnID, Amount: event.Amount, Currency: event.Currency}
+	return CompletePayment(paymentID, paid, "fake payment succeeded")
+}
+
+func newFakeGateway() *payments.FakeGateway {
+	return payments.NewFakeGateway("fake-"+uuid.NewString()[:8], "")
+}
+
+func reloadPayment(t *testing.T, id uuid.UUID) models.Payment {
+	t.Helper()
+	var payment models.Payment
+	if err := database.DB.Preload("StudentBundle").First(&payment, "id = ?", id).Error; err != nil { t.Fatal(err) }
+	return payment
+}
+
+func TestCompletePaymentFulfilsAndIsIdempotent(t *testing.T) {
+	setupPaymentDB(t)
+	gateway := newFakeGateway()
+	payment, orderID := pendingBundlePayment(t, gateway, 40)
+	body := fmt.Sprintf(`{"order_id": %q, "succeeded": true}`, orderID)
+
+	outcome, err := payViaWebhook(t, gateway, body)
+	if err != nil { t.Fatal(err) }
+	if outcome != PaymentFulfilled {
+		t.Fatalf("outcome = %s, want %s", outcome, PaymentFulfilled)
+	}
+	got := reloadPayment(t, payment.ID)
+	if got.Status != "succeeded" || got.StudentBundle.Status != "active" {
+		t.Fatalf("payment %s, bundle %s; want succeeded and active", got.Status, got.StudentBundle.Status)
+	}
+
+	outcome, err = payViaWebhook(t, gateway, body)
+	if err != nil { t.Fatal(err) }
+	if outcome != PaymentAlreadyFulfilled {
+		t.Fatalf("repeat outcome = %s, want %s", outcome, PaymentAlreadyFulfilled)
+	}
+}
+
+func TestCompletePaymentFlagsLatePaymentsForRefund(t *testing.T) {
+	setupPaymentDB(t)
+	for _, status := range []string{"expired", "cancelled"} {
+		t.Run(status, func(t *testing.T) {
+			gateway := newFakeGateway()
+			payment, orderID := pendingBundlePayment(t, gateway, 40)
+			database.DB.Model(&models.Payment{}).Where("id = ?", payment.ID).Update("status", status)
+
+			outcome, err := payViaWebhook(t, gateway, fmt.Sprintf(`{"order_id": %q, "succeeded": true}`, orderID))
+			if err != nil { t.Fatal(err) }
+			if outcome != PaymentLate {
+				t.Fatalf("outcome = %s, want %s", outcome, PaymentLate)
+			}
+			got := reloadPayment(t, payment.ID)
+			if got.RefundStatus == nil || *got.RefundStatus != "requested" {
+				t.Fatalf("refund status = %v, want requested", got.RefundStatus)
+			}
+			if got.StudentBundle.Status != "pending_payment" {
+				t.Fatalf("bundle status = %s, want it left alone", got.StudentBundle.Status)
+			}
+		})
+	}
+}
+
+func TestCompletePaymentHoldsMismatchedAmountsForReview(t *testing.T) {
+	setupPaymentDB(t)
+	gateway := newFakeGateway()
+	payment, orderID := pendingBundlePayment(t, gateway, 40)
+
+	outcome, err := payViaWebhook(t, gateway, fmt.Sprintf(`{"order_id": %q, "succeeded": true, "amount": 35}`, orderID))
+	if err != nil { t.Fatal(err) }
+	if outcome != PaymentNeedsReview {
+		t.Fatalf("outcome = %s, want %s", outcome, PaymentNeedsReview)
+	}
+	got := reloadPayment(t, payment.ID)
+	if got.Status != "needs_review" || got.PaidAmount == nil || *got.PaidAmount != 35 {
+		t.Fatalf("payment %s paid %v; want needs_review with 35 paid", got.Status, got.PaidAmount)
+	}
+	if got.StudentBundle.Status != "pending_payment" {
+		t.Fatalf("bundle status = %s, want it held as pending_payment", got.StudentBundle.Status)
+	}
+}
+
+func TestFindPaymentForWebhookMatchesMpesaCallbacks(t *testing.T) {
+	setupPaymentDB(t)
+	t.Setenv("MPESA_WEBHOOK_SECRET", "s3cret")
+	t.Setenv("MPESA_WEBHOOK_ALLOWED_IPS", "")
+
+	suffix := uuid.NewString()[:8]
+	checkoutID, merchantRequestID := "ws_CO_"+suffix, "29115-"+suffix
+	payment := models.Payment{Amount: 1500, Currency: "KES", Provider: "mpesa", Status: "pending", ProviderOrderID: &checkoutID, MerchantRequestID: &merchantRequestID}
+	create(t, &payment)
+
+	for name, reference := range map[string]string{
+		"invoice number":    "LANGTUTOR-" + payment.ID.String(),
+		"request reference": "LANGTUTOR-garbled",
+	} {
+		t.Run(name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"Body": {"stkCallback": {"MerchantRequestID": %q, "CheckoutRequestID": %q,
+				"ResultCode": 0, "ResultDesc": "ok", "Reference": %q}}}`, merchantRequestID, checkoutID, reference)
+			event, err := payments.MpesaGateway{}.VerifyWebhook(payments.WebhookRequest{Query: url.Values{"secret": {"s3cret"}}, Body: []byte(body)})
+			if err != nil { t.Fatal(err) }
+
+			found, err := FindPaymentForWebhook("mpesa", event)
+			if err != nil {
+				t.Fatalf("payment not found: %v", err)
+			}
+			if found.ID != payment.ID {
+				t.Fatalf("found payment %s, want %s", found.ID, payment.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	config "github.com/anjiri1684/language_tutor/configs"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func roundPrice(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// SlotMinutes is the length of a slot in whole minutes.
+func SlotMinutes(slot models.AvailabilitySlot) int {
+	return int(slot.EndTime.Sub(slot.StartTime).Minutes())
+}
+
+// DurationPrice scales a rate quoted per unitMinutes to a session of the given length.
+func DurationPrice(rate float64, unitMinutes, minutes int) float64 {
+	if unitMinutes <= 0 {
+		unitMinutes = 60
+	}
+	return roundPrice(rate * float64(minutes) / float64(unitMinutes))
+}
+
+// TrialPrice applies the teacher's trial discount to the normal session price.
+func TrialPrice(sessionPrice float64, teacher models.Teacher) float64 {
+	discount := math.Min(math.Max(teacher.TrialDiscountPercent, 0), 100)
+	return roundPrice(sessionPrice * (100 - discount) / 100)
+}
+
+// SessionPrice is what a teacher charges per Language.SessionMinutes of a language: their own
+// rate when they have set one, otherwise the language's default price.
+func SessionPrice(tx *gorm.DB, teacherID uuid.UUID, language models.Language) (float64, string) {
+	var teacherLanguage models.TeacherLanguage
+	err := tx.First(&teacherLanguage, "teacher_user_id = ? AND language_id = ?", teacherID, language.ID).Error
+	if err != nil || teacherLanguage.PricePerSession == nil {
+		return language.PricePerSession, language.Currency
+	}
+
+	currency := language.Currency
+	if teacherLanguage.Currency != nil && *teacherLanguage.Currency != "" {
+		currency = *teacherLanguage.Currency
+	}
+	return *teacherLanguage.PricePerSession, currency
+}
+
+// CheckTeacherPriceBounds enforces the admin-configured range for teacher rates on a language.
+// Rates are always quoted in the language's currency, since credit balances, M-Pesa conversion
+// and price search all assume it.
+func CheckTeacherPriceBounds(price float64, currency string, language models.Language) error {
+	if currency != language.Currency {
+		return fmt.Errorf("rates for %s must be set in %s", language.Name, language.Currency)
+	}
+	if language.MinTeacherPrice != nil && price < *language.MinTeacherPrice {
+		return fmt.Errorf("the minimum rate for %s is %.2f %s", language.Name, *language.MinTeacherPrice, language.Currency)
+	}
+	if language.MaxTeacherPrice != nil && price > *language.MaxTeacherPrice {
+		return fmt.Errorf("the maximum rate for %s is %.2f %s", language.Name, *language.MaxTeacherPrice, language.Currency)
+	}
+	return nil
+}
+
+// TeacherEarnings is the teacher's share of a booking after the platform commission.
+// Bookings snapshot the teacher's rate in Price, so later rate changes don't alter past earnings.
+func TeacherEarnings(booking models.Booking) float64 {
+	commissionRate, _ := strconv.ParseFloat(config.Config("PLATFORM_COMMISSION_RATE"), 64)
+	return booking.Price * (1 - commissionRate)
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/anjiri1684/language_tutor/models"
+)
+
+func TestDurationPrice(t *testing.T) {
+	cases := []struct {
+		rate                 float64
+		unitMinutes, minutes int
+		want                 float64
+	}{
+		{30, 60, 60, 30},
+		{30, 60, 30, 15},
+		{30, 60, 90, 45},
+		{25, 45, 30, 16.67},
+		{20, 0, 30, 10},
+	}
+	for _, tc := range cases {
+		if got := DurationPrice(tc.rate, tc.unitMinutes, tc.minutes); got != tc.want {
+			t.Errorf("DurationPrice(%.2f, %d, %d) = %.2f, want %.2f", tc.rate, tc.unitMinutes, tc.minutes, got, tc.want)
+		}
+	}
+}
+
+func TestTrialPrice(t *testing.T) {
+	cases := []struct {
+		discount float64
+		want     float64
+	}{
+		{0, 20},
+		{50, 10},
+		{33, 13.4},
+		{100, 0},
+		{150, 0},
+		{-10, 20},
+	}
+	for _, tc := range cases {
+		if got := TrialPrice(20, models.Teacher{TrialDiscountPercent: tc.discount}); got != tc.want {
+			t.Errorf("TrialPrice(20, %.0f%% off) = %.2f, want %.2f", tc.discount, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/payments"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPaymentNotRefundable = errors.New("this payment cannot be refunded")
+	ErrRefundExceedsPayment = errors.New("refund is more than what is left to refund on this payment")
+	ErrProviderRefundFailed = errors.New("the payment provider did not accept the refund")
+)
+
+// paidAmount is what the student actually paid, which may differ from what was charged
+// for payments accepted after review.
+func paidAmount(payment models.Payment) (float64, string) {
+	amount, currency := payment.Amount, payment.Currency
+	if payment.PaidAmount != nil {
+		amount = *payment.PaidAmount
+	}
+	if payment.PaidCurrency != nil {
+		currency = *payment.PaidCurrency
+	}
+	return amount, currency
+}
+
+func refundTotal(tx *gorm.DB, paymentID uuid.UUID, statuses []string) (float64, error) {
+	var total float64
+	err := tx.Model(&models.Refund{}).
+		Where("payment_id = ? AND status IN ?", paymentID, statuses).
+		Select("COALESCE(SUM(amount), 0)").Scan(&total).Error
+	return total, err
+}
+
+// RefundableAmount is what was paid less every refund that has gone out or is still in flight.
+func RefundableAmount(tx *gorm.DB, payment models.Payment) (float64, error) {
+	refunded, err := refundTotal(tx, payment.ID, []string{"pending", "processing", "succeeded"})
+	if err != nil {
+		return 0, err
+	}
+	paid, _ := paidAmount(payment)
+	return math.Round((paid-refunded)*100) / 100, nil
+}
+
+// creditEquivalent converts part of a payment into the platform credit it represents, using the
+// booking or bundle price so M-Pesa payments in KES come back at the price the student was quoted.
+func creditEquivalent(tx *gorm.DB, payment models.Payment, amount float64) (float64, uuid.UUID, error) {
+	var basePrice float64
+	var studentID uuid.UUID
+	switch {
+	case payment.BookingID != nil:
+		var booking models.Booking
+		if err := tx.First(&booking, "id = ?", payment.BookingID).Error; err != nil {
+			return 0, uuid.Nil, err
+		}
+		basePrice, studentID = booking.Price, booking.StudentID
+	case payment.StudentBundleID != nil:
+		var studentBundle models.StudentBundle
+		if err := tx.Preload("Bundle").First(&studentBundle, "id = ?", payment.StudentBundleID).Error; err != nil {
+			return 0, uuid.Nil, err
+		}
+		basePrice, studentID = studentBundle.Bundle.Price, studentBundle.StudentID
+	default:
+		return 0, uuid.Nil, ErrPaymentNotRefundable
+	}
+
+	paid, _ := paidAmount(payment)
+	if paid <= 0 {
+		return 0, uuid.Nil, ErrPaymentNotRefundable
+	}
+	return math.Round(amount/paid*basePrice*100) / 100, studentID, nil
+}
+
+// applyRefundsToPayment updates the payment's refund summary from its successful refunds.
+func applyRefundsToPayment(tx *gorm.DB, payment *models.Payment) error {
+	refunded, err := refundTotal(tx, payment.ID, []string{"succeeded"})
+	if err != nil {
+		return err
+	}
+	paid, _ := paidAmount(*payment)
+
+	approved := "approved"
+	payment.RefundStatus = &approved
+	payment.RefundAmount = &refunded
+	if math.Round(refunded*100) >= math.Round(paid*100) {
+		payment.Status = "refunded"
+	} else {
+		payment.Status = "partially_refunded"
+	}
+	return tx.Save(payment).Error
+}
+
+// recordCreditRefund logs a refund that was already added to the student's credit balance.
+func recordCreditRefund(tx *gorm.DB, payment *models.Payment, amount, credit float64, reason string, processedBy *uuid.UUID) (models.Refund, error) {
+	_, currency := paidAmount(*payment)
+	refund := models.Refund{
+		PaymentID:     payment.ID,
+		Amount:        math.Round(amount*100) / 100,
+		Currency:      currency,
+		Destination:   "credit",
+		Provider:      payment.Provider,
+		CreditAmount:  &credit,
+		Status:        "succeeded",
+		Reason:        reason,
+		ProcessedByID: processedBy,
+	}
+	return refund, tx.Create(&refund).Error
+}
+
+// IssueRefund returns amount of a payment to the student, or everything still refundable when
+// amount is zero. Credit payments and toCredit refunds are added to the student's credit balance
+// straight away; anything else is sent back through the payment provider, which may finish later.
+func IssueRefund(paymentID uuid.UUID, amount float64, toCredit bool, reason string, processedBy *uuid.UUID) (models.Refund, error) {
+	var refund models.Refund
+	var payment models.Payment
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, "id = ?", paymentID).Error; err != nil {
+			return err
+		}
+		if payment.Status != "succeeded" && payment.Status != "partially_refunded" {
+			return ErrPaymentNotRefundable
+		}
+		if payment.Provider == "bundle" {
+			return ErrPaymentNotRefundable
+		}
+
+		refundable, err := RefundableAmount(tx, payment)
+		if err != nil { return err }
+		if amount <= 0 {
+			amount = refundable
+		}
+		amount = math.Round(amount*100) / 100
+		if amount <= 0 || amount > refundable {
+			return ErrRefundExceedsPayment
+		}
+
+		toProvider := !toCredit && payment.Provider != "credit"
+		if toProvider && payment.Provider == "paypal" && payment.ProviderTxnID == nil {
+			// PayPal refunds go against the capture, and this payment never recorded one.
+			return ErrPaymentNotRefundable
+		}
+		_, currency := paidAmount(payment)
+		if toProvider && currency == "KES" {
+			// M-Pesa only pays out whole shillings, so record the amount that is actually sent.
+			amount = math.Min(math.Round(amount), math.Floor(refundable))
+			if amount <= 0 {
+				return ErrRefundExceedsPayment
+			}
+		}
+
+		if !toProvider {
+			credit, studentID, err := creditEquivalent(tx, payment, amount)
+			if err != nil { return err }
+			if err := AddCredit(tx, studentID, credit); err != nil { return err }
+			if refund, err = recordCreditRefund(tx, &payment, amount, credit, reason, processedBy); err != nil { return err }
+			payment.RefundReason = &reason
+			return applyRefundsToPayment(tx, &payment)
+		}
+
+		refund = models.Refund{
+			PaymentID:     payment.ID,
+			Amount:        amount,
+			Currency:      currency,
+			Destination:   "provider",
+			Provider:      payment.Provider,
+			Status:        "pending",
+			Reason:        reason,
+			ProcessedByID: processedBy,
+		}
+		return tx.Create(&refund).Error
+	})
+	if err != nil || refund.Destination == "credit" {
+		return refund, err
+	}
+
+	err = sendProviderRefund(&refund, payment)
+	return refund, err
+}
+
+// sendProviderRefund asks the gateway to move the money. The refund row already exists so a crash
+// between the call and the update leaves a pending refund to reconcile rather than a lost one.
+func sendProviderRefund(refund *models.Refund, payment models.Payment) error {
+	gateway, ok := payments.Get(payment.Provider)
+	if !ok {
+		return failRefund(refund, fmt.Sprintf("no payment gateway registered for %s", payment.Provider))
+	}
+
+	req := payments.RefundRequest{
+		RefundID: refund.ID.String(),
+		Amount:   refund.Amount,
+		Currency: refund.Currency,
+		Reason:   refund.Reason,
+	}
+	if payment.ProviderTxnID != nil {
+		req.ProviderTxnID = *payment.ProviderTxnID
+	}
+	if payment.PayerPhone != nil {
+		req.PhoneNumber = *payment.PayerPhone
+	}
+
+	result, err := gateway.Refund(req)
+	if err != nil {
+		log.Printf("🔥 %s refund %s for payment %s failed: %v", gateway.Name(), refund.ID, payment.ID, err)
+		return failRefund(refund, err.Error())
+	}
+
+	if result.ProviderRefundID != "" {
+		refund.ProviderRefundID = &result.ProviderRefundID
+	}
+	switch result.Status {
+	case payments.StatusSucceeded:
+		return finishRefund(refund, true, "")
+	case payments.StatusFailed:
+		return failRefund(refund, "refund declined by "+gateway.Name())
+	}
+	return markRefundProcessing(refund)
+}
+
+// markRefundProcessing records that the provider accepted the refund and will report back later,
+// and moves the payment's refund status along so the request leaves the admin queue. The callback
+// can arrive before this runs, in which case the refund is already settled and is left alone.
+func markRefundProcessing(refund *models.Refund) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var payment models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, "id = ?", refund.PaymentID).Error; err != nil {
+			return err
+		}
+		if refund.ProviderRefundID != nil {
+			if err := tx.Model(&models.Refund{}).Where("id = ?", refund.ID).Update("provider_refund_id", *refund.ProviderRefundID).Error; err != nil { return err }
+		}
+
+		result := tx.Model(&models.Refund{}).Where("id = ? AND status = ?", refund.ID, "pending").Update("status", "processing")
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return tx.First(refund, "id = ?", refund.ID).Error
+		}
+		refund.Status = "processing"
+		return tx.Model(&payment).Update("refund_status", "processing").Error
+	})
+}
+
+func failRefund(refund *models.Refund, reason string) error {
+	if err := finishRefund(refund, false, reason); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrProviderRefundFailed, reason)
+}
+
+// finishRefund records the final outcome of a refund and brings its payment up to date.
+// A failed refund marks the payment's refund as failed so it shows up for admins to retry.
+func finishRefund(refund *models.Refund, succeeded bool, failureReason string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var payment models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, "id = ?", refund.PaymentID).Error; err != nil {
+			return err
+		}
+
+		if succeeded {
+			refund.Status = "succeeded"
+		} else {
+			refund.Status = "failed"
+			refund.FailureReason = &failureReason
+		}
+		if err := tx.Save(refund).Error; err != nil { return err }
+
+		if succeeded {
+			return applyRefundsToPayment(tx, &payment)
+		}
+		failed := "failed"
+		return tx.Model(&payment).Update("refund_status", failed).Error
+	})
+}
+
+// CompleteProviderRefund applies a provider's callback for a refund that was left processing.
+// Refunds are found by our own ID when the provider echoes it back, since the callback can
+// arrive before the provider's refund ID has been stored.
+func CompleteProviderRefund(provider string, event *payments.RefundEvent) (models.Refund, error) {
+	var refund models.Refund
+	query := database.DB.Where("provider = ?", provider)
+	if _, err := uuid.Parse(event.RefundID); err == nil {
+		query = query.Where("id = ?", event.RefundID)
+	} else {
+		query = query.Where("provider_refund_id = ?", event.ProviderRefundID)
+	}
+	if err := query.First(&refund).Error; err != nil {
+		return refund, err
+	}
+	if refund.Status == "succeeded" || refund.Status == "failed" {
+		return refund, nil
+	}
+	if refund.ProviderRefundID == nil && event.ProviderRefundID != "" {
+		refund.ProviderRefundID = &event.ProviderRefundID
+	}
+	err := finishRefund(&refund, event.Succeeded, event.Description)
+	return refund, err
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SlotConflictError struct {
+	Conflicts []models.AvailabilitySlot
+}
+
+func (e *SlotConflictError) Error() string {
+	return fmt.Sprintf("this time overlaps with %d existing availability slot(s)", len(e.Conflicts))
+}
+
+func FindOverlappingSlots(tx *gorm.DB, teacherID uuid.UUID, startTime, endTime time.Time, excludeSlotIDs ...uuid.UUID) ([]models.AvailabilitySlot, error) {
+	query := tx.Where("teacher_id = ? AND start_time < ? AND end_time > ?", teacherID, endTime, startTime)
+	if len(excludeSlotIDs) > 0 {
+		query = query.Where("id NOT IN ?", excludeSlotIDs)
+	}
+
+	var conflicts []models.AvailabilitySlot
+	if err := query.Order("start_time asc").Find(&conflicts).Error; err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+func CheckSlotConflicts(tx *gorm.DB, teacherID uuid.UUID, startTime, endTime time.Time, excludeSlotIDs ...uuid.UUID) error {
+	conflicts, err := FindOverlappingSlots(tx, teacherID, startTime, endTime, excludeSlotIDs...)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return &SlotConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// IsSlotOverlapViolation reports whether err came from the availability_slots exclusion constraint,
+// which catches overlaps that slip past CheckSlotConflicts under concurrent writes.
+func IsSlotOverlapViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23P01"
+}
+
+// ReleaseSeat frees one seat on a slot and reopens it for booking,
+// unless a waitlisted student is in line, in which case the seat is held for them instead.
+func ReleaseSeat(tx *gorm.DB, slotID uuid.UUID) error {
+	var slot models.AvailabilitySlot
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&slot, "id = ?", slotID).Error; err != nil {
+		return err
+	}
+
+	offered, err := offerSeatToWaitlist(tx, &slot)
+	if err != nil {
+		return err
+	}
+	if offered {
+		return nil
+	}
+
+	if slot.CurrentStudents > 0 {
+		slot.CurrentStudents--
+	}
+	if slot.CurrentStudents < slot.MaxStudents {
+		slot.Status = "available"
+	}
+	return tx.Save(&slot).Error
+}
+
+var ErrSlotUnavailable = errors.New("this class is full or no longer available")
+
+// ReserveSeat takes one seat on a slot, marking it booked or full when capacity is reached.
+func ReserveSeat(tx *gorm.DB, slotID uuid.UUID) error {
+	var slot models.AvailabilitySlot
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&slot, "id = ?", slotID).Error; err != nil {
+		return err
+	}
+
+	if slot.Status == "full" || slot.Status == "booked" || slot.CurrentStudents >= slot.MaxStudents {
+		return ErrSlotUnavailable
+	}
+	slot.CurrentStudents++
+	if slot.CurrentStudents >= slot.MaxStudents {
+		if slot.MaxStudents > 1 {
+			slot.Status = "full"
+		} else {
+			slot.Status = "booked"
+		}
+	}
+	return tx.Save(&slot).Error
+}
+
+// MoveBookingToSlot transfers a booking's seat from its current slot to newSlotID.
+// Both slots are locked in a fixed order so concurrent moves cannot deadlock.
+func MoveBookingToSlot(tx *gorm.DB, booking *models.Booking, newSlotID uuid.UUID) error {
+	var locked []models.AvailabilitySlot
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", []uuid.UUID{booking.AvailabilitySlotID, newSlotID}).
+		Order("id").
+		Find(&locked).Error; err != nil {
+		return err
+	}
+
+	if err := ReserveSeat(tx, newSlotID); err != nil {
+		return err
+	}
+	if err := ReleaseSeat(tx, booking.AvailabilitySlotID); err != nil {
+		return err
+	}
+
+	booking.AvailabilitySlotID = newSlotID
+	return tx.Model(&models.Booking{}).Where("id = ?", booking.ID).Update("availability_slot_id", newSlotID).Error
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anjiri1684/language_tutor/models"
+)
+
+const classTimeLayout = "Mon, 02 Jan 2006 15:04 MST"
+
+// ParseTimeZone accepts IANA zone names only; time.LoadLocation also accepts "" and "Local", which we don't want stored.
+func ParseTimeZone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" || strings.HasPrefix(name, "/") {
+		return nil, fmt.Errorf("%q is not a valid IANA time zone", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a valid IANA time zone", name)
+	}
+	return loc, nil
+}
+
+// UserLocation returns the user's configured time zone, falling back to UTC.
+func UserLocation(user models.User) *time.Location {
+	if user.TimeZone != nil {
+		if loc, err := ParseTimeZone(*user.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+func FormatForUser(t time.Time, user models.User) string {
+	return t.In(UserLocation(user)).Format(classTimeLayout)
+}
+
+// ClassTimeForBoth describes a class time in the student's and the teacher's local zones.
+func ClassTimeForBoth(t time.Time, student, teacher models.User) string {
+	studentTime := FormatForUser(t, student)
+	teacherTime := FormatForUser(t, teacher)
+	if studentTime == teacherTime {
+		return studentTime
+	}
+	return fmt.Sprintf("%s (student's time) / %s (teacher's time)", studentTime, teacherTime)
+}
+
+// ParseScheduleTime reads an RFC3339 timestamp, or a local "2006-01-02T15:04[:05]" wall time in loc.
+func ParseScheduleTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected RFC3339 or a local time like 2006-01-02T15:04", value)
+}
+
+// ParseLocalDateRange turns inclusive YYYY-MM-DD dates in loc into a [from, to) instant range.
+// Either bound may be empty.
+func ParseLocalDateRange(fromDate, toDate string, loc *time.Location) (*time.Time, *time.Time, error) {
+	var from, to *time.Time
+	if fromDate != "" {
+		t, err := time.ParseInLocation("2006-01-02", fromDate, loc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", fromDate)
+		}
+		from = &t
+	}
+	if toDate != "" {
+		t, err := time.ParseInLocation("2006-01-02", toDate, loc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", toDate)
+		}
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, nil, fmt.Errorf("from date must not be after to date")
+	}
+	return from, to, nil
+}
+
+// LocalizeSlot fills in the slot's times as seen from loc.
+func LocalizeSlot(slot *models.AvailabilitySlot, loc *time.Location) {
+	if slot == nil || slot.StartTime.IsZero() {
+		return
+	}
+	start := slot.StartTime.In(loc)
+	end := slot.EndTime.In(loc)
+	slot.LocalStartTime = &start
+	slot.LocalEndTime = &end
+	slot.TimeZone = loc.String()
+}
+
+func LocalizeBooking(booking *models.Booking, loc *time.Location) {
+	LocalizeSlot(&booking.AvailabilitySlot, loc)
+	LocalizeSlot(booking.ProposedSlot, loc)
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLocalDateRange(t *testing.T) {
+	nairobi, err := time.LoadLocation("Africa/Nairobi")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	from, to, err := ParseLocalDateRange("2030-03-01", "2030-03-07", nairobi)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := time.Date(2030, 2, 28, 21, 0, 0, 0, time.UTC); !from.Equal(want) {
+		t.Errorf("from = %s, want %s", from.UTC(), want)
+	}
+	// The to date is inclusive, so the range ends at the following local midnight.
+	if want := time.Date(2030, 3, 7, 21, 0, 0, 0, time.UTC); !to.Equal(want) {
+		t.Errorf("to = %s, want %s", to.UTC(), want)
+	}
+}
+
+func TestParseLocalDateRangeOpenEnds(t *testing.T) {
+	from, to, err := ParseLocalDateRange("", "", time.UTC)
+	if err != nil || from != nil || to != nil {
+		t.Fatalf("got %v, %v, %v; want no bounds", from, to, err)
+	}
+
+	from, to, err = ParseLocalDateRange("2030-03-01", "", time.UTC)
+	if err != nil || from == nil || to != nil {
+		t.Fatalf("got %v, %v, %v; want only a lower bound", from, to, err)
+	}
+}
+
+func TestParseLocalDateRangeRejectsBadInput(t *testing.T) {
+	for _, tc := range []struct{ from, to string }{
+		{"01/03/2030", ""},
+		{"", "2030-13-01"},
+		{"2030-03-08", "2030-03-01"},
+	} {
+		if _, _, err := ParseLocalDateRange(tc.from, tc.to, time.UTC); err == nil {
+			t.Errorf("ParseLocalDateRange(%q, %q) accepted bad input", tc.from, tc.to)
+		}
+	}
+}
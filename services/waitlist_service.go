@@ -0,0 +1,107 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	config "github.com/anjiri1684/language_tutor/configs"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultWaitlistClaimMinutes = 60
+
+var ErrWaitlistClaimInvalid = errors.New("this waitlist offer is invalid or has expired")
+
+func WaitlistClaimWindow() time.Duration {
+	minutes, err := strconv.Atoi(config.Config("WAITLIST_CLAIM_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = defaultWaitlistClaimMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func WaitlistClaimLink(token string) string {
+	frontendURL := config.Config("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "https://www.classlearning.co.ke"
+	}
+	return frontendURL + "/waitlist/claim?token=" + token
+}
+
+// offerSeatToWaitlist hands a freed seat on slot to the longest-waiting student, if any.
+// The seat stays counted in CurrentStudents while the offer is open so nobody else can take it.
+func offerSeatToWaitlist(tx *gorm.DB, slot *models.AvailabilitySlot) (bool, error) {
+	if !slot.StartTime.After(time.Now()) {
+		return false, nil
+	}
+
+	var entry models.WaitlistEntry
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("availability_slot_id = ? AND status = ?", slot.ID, "waiting").
+		Order("created_at asc").
+		First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return false, err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	now := time.Now()
+	expiresAt := now.Add(WaitlistClaimWindow())
+	if expiresAt.After(slot.StartTime) {
+		expiresAt = slot.StartTime
+	}
+	entry.Status = "offered"
+	entry.ClaimToken = &token
+	entry.OfferedAt = &now
+	entry.OfferExpiresAt = &expiresAt
+	entry.NotifiedAt = nil
+	return true, tx.Save(&entry).Error
+}
+
+// ClaimWaitlistSeat converts an open waitlist offer into the student's seat on slotID.
+func ClaimWaitlistSeat(tx *gorm.DB, token string, studentID, slotID uuid.UUID) error {
+	var entry models.WaitlistEntry
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entry, "claim_token = ?", token).Error; err != nil {
+		return ErrWaitlistClaimInvalid
+	}
+	if entry.Status != "offered" || entry.StudentID != studentID || entry.AvailabilitySlotID != slotID {
+		return ErrWaitlistClaimInvalid
+	}
+	if entry.OfferExpiresAt == nil || time.Now().After(*entry.OfferExpiresAt) {
+		return ErrWaitlistClaimInvalid
+	}
+
+	entry.Status = "claimed"
+	return tx.Save(&entry).Error
+}
+
+// ExpireWaitlistOffer gives up a lapsed offer's held seat, which passes it on to the next student in line.
+func ExpireWaitlistOffer(tx *gorm.DB, entryID uuid.UUID) error {
+	var entry models.WaitlistEntry
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entry, "id = ?", entryID).Error; err != nil {
+		return err
+	}
+	if entry.Status != "offered" {
+		return nil
+	}
+
+	entry.Status = "expired"
+	if err := tx.Save(&entry).Error; err != nil {
+		return err
+	}
+	return ReleaseSeat(tx, entry.AvailabilitySlotID)
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/anjiri1684/language_tutor/database"
+	"github.com/anjiri1684/language_tutor/models"
+	"github.com/anjiri1684/language_tutor/payments"
+	"github.com/google/uuid"
+)
+
+// Headers that must never be persisted alongside a rejected callback.
+var redactedWebhookHeaders = map[string]bool{
+	"authorization":    true,
+	"cookie":           true,
+	"x-webhook-secret": true,
+}
+
+// RecordWebhookRejection stores a refused payment callback in the audit table.
+func RecordWebhookRejection(provider string, req payments.WebhookRequest, reason string, paymentID *uuid.UUID) {
+	headers := map[string][]string{}
+	for key, values := range req.Headers {
+		if redactedWebhookHeaders[strings.ToLower(key)] {
+			headers[key] = []string{"[redacted]"}
+			continue
+		}
+		headers[key] = values
+	}
+	headerJSON, _ := json.Marshal(headers)
+
+	entry := models.WebhookAuditLog{
+		Provider:  provider,
+		RemoteIP:  req.RemoteIP,
+		Reason:    reason,
+		Headers:   string(headerJSON),
+		Body:      string(req.Body),
+		PaymentID: paymentID,
+	}
+	if err := database.DB.Create(&entry).Error; err != nil {
+		log.Printf("🔥 Failed to record rejected %s webhook: %v", provider, err)
+	}
+	log.Printf("Rejected %s webhook from %s: %s", provider, req.RemoteIP, reason)
+}
+
+// ConfirmPaymentWithProvider double-checks a reported outcome by querying the provider directly,
+// so a forged callback can neither confirm a booking nor fail a payment the student did make.
+// Providers without a status query rely on the checks in VerifyWebhook alone.
+func ConfirmPaymentWithProvider(gateway payments.Gateway, payment models.Payment, succeeded bool) error {
+	reference := ""
+	if payment.ProviderOrderID != nil {
+		reference = *payment.ProviderOrderID
+	}
+
+	result, err := gateway.QueryStatus(reference)
+	if errors.Is(err, payments.ErrNotSupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not confirm payment with %s: %v", gateway.Name(), err)
+	}
+	expected := payments.StatusFailed
+	if succeeded {
+		expected = payments.StatusSucceeded
+	}
+	if result.Status != expected {
+		return fmt.Errorf("%s reports this payment as %s", gateway.Name(), result.Status)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package websocket
+
+import (
+    "log"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// Event types pushed to connected clients. Chat messages are still sent as raw models.Message
+// objects; everything else arrives wrapped in an Event envelope.
+const (
+    EventBookingConfirmed    = "booking.confirmed"
+    EventRescheduleRequested = "booking.reschedule_requested"
+    EventRescheduleProcessed = "booking.reschedule_processed"
+    EventMeetingLinkAdded    = "booking.meeting_link_added"
+    EventRefundDecided       = "payment.refund_decided"
+    EventPayoutDecided       = "payout.decided"
+    EventBadgeAwarded        = "gamification.badge_awarded"
+    EventWaitlistOffer       = "waitlist.offer"
+)
+
+type Event struct {
+    Type      string      `json:"type"`
+    Data      interface{} `json:"data"`
+    Timestamp time.Time   `json:"timestamp"`
+}
+
+// Publish queues an event for each connected recipient. It never blocks the caller:
+// if the hub is backed up the event is dropped, since clients can always re-fetch state over HTTP.
+func Publish(eventType string, data interface{}, userIDs ...uuid.UUID) {
+    event := Event{Type: eventType, Data: data, Timestamp: time.Now().UTC()}
+    for _, userID := range userIDs {
+        select {
+        case Notify <- &Notification{UserID: userID, Payload: event}:
+        default:
+            log.Printf("Notification queue full, dropping %s event for user %s", eventType, userID)
+        }
+    }
+}
@@ -25,12 +25,19 @@ var clientsMu sync.RWMutex
 var Register = make(chan *Client)
 var Unregister = make(chan *Client)
 var Broadcast = make(chan *models.Message)
+var Notify = make(chan *Notification, 64)
 
-func init() {
-    go RunHub()
+type Notification struct {
+    UserID  uuid.UUID
+    Payload interface{}
 }
 
+// RunHub delivers messages and notifications to clients connected to this instance and,
+// through the fan-out backend, to clients connected to other instances. It must be started
+// once, after the database connection is open.
 func RunHub() {
+    startFanout()
+
     for {
         select {
         case client := <-Register:
@@ -45,6 +52,9 @@ func RunHub() {
                 delete(clients, client.UserID)
             }
             clientsMu.Unlock()
+        case notification := <-Notify:
+            deliver(notification.UserID, notification.Payload)
+            publishRemote([]uuid.UUID{notification.UserID}, notification.Payload, nil)
         case message := <-Broadcast:
             var participantIDs []uuid.UUID
             err := database.DB.
@@ -56,24 +66,45 @@ func RunHub() {
                 continue
             }
 
-            clientsMu.RLock()
+            var recipients []uuid.UUID
             for _, participantID := range participantIDs {
                 if participantID == message.SenderID {
                     continue
                 }
-                if conn, ok := clients[participantID]; ok {
-                    if err := conn.WriteJSON(message); err != nil {
-                        log.Printf("Error sending message to client %s: %v", participantID, err)
-                        conn.Close()
-                        clientsMu.RUnlock()
-                        clientsMu.Lock()
-                        delete(clients, participantID)
-                        clientsMu.Unlock()
-                        clientsMu.RLock()
-                    }
+                deliver(participantID, message)
+                recipients = append(recipients, participantID)
+            }
+            // Recipients may also be connected to other instances, so fan out regardless of local delivery.
+            publishRemote(recipients, message, &message.ID)
+        case env := <-inbound:
+            var payload interface{} = env.Payload
+            if len(env.Payload) == 0 && env.MessageID != nil {
+                var message models.Message
+                if err := database.DB.First(&message, "id = ?", *env.MessageID).Error; err != nil {
+                    log.Printf("Error loading fanned-out message %s: %v", *env.MessageID, err)
+                    continue
                 }
+                payload = message
+            }
+            for _, userID := range env.UserIDs {
+                deliver(userID, payload)
             }
-            clientsMu.RUnlock()
         }
     }
-}
\ No newline at end of file
+}
+
+// deliver writes payload to the user's connection if they are connected to this instance.
+func deliver(userID uuid.UUID, payload interface{}) {
+    clientsMu.Lock()
+    defer clientsMu.Unlock()
+
+    conn, ok := clients[userID]
+    if !ok {
+        return
+    }
+    if err := conn.WriteJSON(payload); err != nil {
+        log.Printf("Error sending to client %s: %v", userID, err)
+        conn.Close()
+        delete(clients, userID)
+    }
+}
@@ -0,0 +1,93 @@
+package websocket
+
+import (
+    "context"
+    "encoding/json"
+    "log"
+    "strings"
+
+    config "github.com/anjiri1684/language_tutor/configs"
+    "github.com/google/uuid"
+)
+
+// Envelope is what instances exchange over the fan-out backend: a payload that was already
+// delivered to local clients on the origin instance and still needs to reach recipients elsewhere.
+// Chat messages too large for the backend travel as MessageID only and are reloaded by receivers.
+type Envelope struct {
+    Origin    uuid.UUID       `json:"origin"`
+    UserIDs   []uuid.UUID     `json:"user_ids"`
+    Payload   json.RawMessage `json:"payload,omitempty"`
+    MessageID *uuid.UUID      `json:"message_id,omitempty"`
+}
+
+// PubSub carries envelopes between API instances. Subscribe blocks, calling handle for
+// every envelope published by any instance (including this one), until ctx is cancelled.
+type PubSub interface {
+    Publish(ctx context.Context, env Envelope) error
+    Subscribe(ctx context.Context, handle func(Envelope)) error
+}
+
+// instanceID tags envelopes so an instance can skip the ones it published itself.
+var instanceID = uuid.New()
+
+var (
+    fanout   PubSub
+    outbound = make(chan Envelope, 256)
+    inbound  = make(chan Envelope, 256)
+)
+
+// newPubSub picks the backend named by WEBSOCKET_PUBSUB: "postgres" (the default) or "none"
+// for a single instance deployment.
+func newPubSub() PubSub {
+    switch strings.ToLower(config.Config("WEBSOCKET_PUBSUB")) {
+    case "none", "local":
+        return nil
+    default:
+        return &PostgresPubSub{DSN: config.Config("DATABASE_URL"), Channel: "websocket_fanout"}
+    }
+}
+
+func startFanout() {
+    fanout = newPubSub()
+    if fanout == nil {
+        log.Println("Websocket fan-out disabled; messages reach clients on this instance only")
+        return
+    }
+
+    ctx := context.Background()
+    go func() {
+        for env := range outbound {
+            if err := fanout.Publish(ctx, env); err != nil {
+                log.Printf("Error publishing websocket envelope: %v", err)
+            }
+        }
+    }()
+    go func() {
+        if err := fanout.Subscribe(ctx, func(env Envelope) {
+            if env.Origin == instanceID {
+                return
+            }
+            inbound <- env
+        }); err != nil {
+            log.Printf("Websocket fan-out subscription stopped: %v", err)
+        }
+    }()
+}
+
+// publishRemote queues a payload for recipients connected to other instances without blocking the hub.
+func publishRemote(userIDs []uuid.UUID, payload interface{}, messageID *uuid.UUID) {
+    if fanout == nil || len(userIDs) == 0 {
+        return
+    }
+    raw, err := json.Marshal(payload)
+    if err != nil {
+        log.Printf("Error encoding websocket payload: %v", err)
+        return
+    }
+
+    select {
+    case outbound <- Envelope{Origin: instanceID, UserIDs: userIDs, Payload: raw, MessageID: messageID}:
+    default:
+        log.Printf("Websocket fan-out queue full, dropping payload for %d recipient(s)", len(userIDs))
+    }
+}
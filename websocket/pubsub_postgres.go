@@ -0,0 +1,84 @@
+package websocket
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "time"
+
+    "github.com/anjiri1684/language_tutor/database"
+    "github.com/jackc/pgx/v5"
+)
+
+// Postgres NOTIFY payloads are limited to 8000 bytes.
+const maxNotifyPayload = 7900
+
+// PostgresPubSub fans envelopes out with LISTEN/NOTIFY on a dedicated connection.
+type PostgresPubSub struct {
+    DSN     string
+    Channel string
+}
+
+func (p *PostgresPubSub) Publish(ctx context.Context, env Envelope) error {
+    body, err := json.Marshal(env)
+    if err != nil {
+        return err
+    }
+    if len(body) > maxNotifyPayload {
+        if env.MessageID == nil {
+            return fmt.Errorf("envelope of %d bytes is too large to publish", len(body))
+        }
+        env.Payload = nil
+        if body, err = json.Marshal(env); err != nil {
+            return err
+        }
+    }
+    return database.DB.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", p.Channel, string(body)).Error
+}
+
+// Subscribe listens until ctx is cancelled, reconnecting with backoff if the connection drops.
+func (p *PostgresPubSub) Subscribe(ctx context.Context, handle func(Envelope)) error {
+    backoff := time.Second
+    for {
+        err := p.listen(ctx, handle)
+        if ctx.Err() != nil {
+            return ctx.Err()
+        }
+        log.Printf("Websocket LISTEN connection lost, retrying in %s: %v", backoff, err)
+        select {
+        case <-time.After(backoff):
+        case <-ctx.Done():
+            return ctx.Err()
+        }
+        if backoff < 30*time.Second {
+            backoff *= 2
+        }
+    }
+}
+
+func (p *PostgresPubSub) listen(ctx context.Context, handle func(Envelope)) error {
+    conn, err := pgx.Connect(ctx, p.DSN)
+    if err != nil {
+        return err
+    }
+    defer conn.Close(context.Background())
+
+    if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{p.Channel}.Sanitize()); err != nil {
+        return err
+    }
+    log.Printf("✅ Listening for websocket fan-out on channel %s", p.Channel)
+
+    for {
+        notification, err := conn.WaitForNotification(ctx)
+        if err != nil {
+            return err
+        }
+        var env Envelope
+        if err := json.Unmarshal([]byte(notification.Payload), &env); err != nil {
+            log.Printf("Ignoring malformed websocket envelope: %v", err)
+            continue
+        }
+        handle(env)
+    }
+}